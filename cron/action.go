@@ -0,0 +1,139 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeoutGrace is how long a timed out command may take to exit after being asked to terminate before it is killed.
+var TimeoutGrace = 5 * time.Second
+
+// DefaultOutputLimit is how many bytes of the end of stdout and of stderr a JobResult keeps by default.
+var DefaultOutputLimit = 4096
+
+// ShellAction runs a script with Shell, by default bash or cmd on Windows, reading the script from stdin.
+type ShellAction struct {
+	Script string
+	Shell string //program the script is piped to, "" means bash, or cmd on Windows
+	Dir string //working directory, "" means the daemon's
+	Env []string //NAME=value variables added to the daemon's environment, later ones win
+	Timeout time.Duration //0 means no timeout
+	OutputLimit int //bytes of the end of stdout and of stderr kept in the JobResult, 0 means DefaultOutputLimit
+	LogFile string //file stdout and stderr are appended to, "" means none
+}
+
+// Run runs the script and sends its result, with the tails of its stdout and stderr, to c. When the timeout expires
+// or ctx is canceled the whole process group of the command is asked to terminate, then killed after TimeoutGrace,
+// and the result has Code TimeoutCode or CanceledCode.
+func (a *ShellAction) Run(ctx context.Context, c chan JobResult) {
+	osterminal := ""
+	switch runtime.GOOS {
+	case "windows":
+		osterminal = "cmd"
+	default:
+		osterminal = "bash"
+	}
+	if a.Shell != "" {
+		osterminal = a.Shell
+	}
+	cmd := exec.Command(osterminal)
+	cmd.Dir = a.Dir
+	if len(a.Env) > 0 {
+		cmd.Env = append(os.Environ(), a.Env...)
+	}
+	cmd.Stdin = strings.NewReader(a.Script + "\nexit\n")
+	limit := a.OutputLimit
+	if limit <= 0 {
+		limit = DefaultOutputLimit
+	}
+	stdout, stderr := &tailBuffer{limit: limit}, &tailBuffer{limit: limit}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if a.LogFile != "" {
+		file, err := os.OpenFile(a.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			c <- JobResult{Code: ErrorCode, Msg: fmt.Sprintf("open log file error: %v", err)}
+			return
+		}
+		defer file.Close()
+		cmd.Stdout, cmd.Stderr = io.MultiWriter(stdout, file), io.MultiWriter(stderr, file)
+	}
+	//children left running in the background must not keep the run waiting for their output
+	cmd.WaitDelay = time.Second
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		c <- JobResult{Code: ErrorCode, Msg: fmt.Sprint(err)}
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	cancel := context.CancelFunc(func() {})
+	if a.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, a.Timeout)
+	}
+	defer cancel()
+	result := JobResult{}
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			result = JobResult{Code: ErrorCode, Msg: fmt.Sprint(err)}
+		} else {
+			result = JobResult{Code: cmd.ProcessState.ExitCode(), Msg: cmd.ProcessState.String()}
+		}
+	case <-ctx.Done():
+		terminateProcessGroup(cmd)
+		select {
+		case <-done:
+		case <-time.After(TimeoutGrace):
+			killProcessGroup(cmd)
+			<-done
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			result = JobResult{Code: TimeoutCode, Msg: fmt.Sprintf("timeout after %s", a.Timeout)}
+		} else {
+			result = JobResult{Code: CanceledCode, Msg: "canceled"}
+		}
+	}
+	result.Stdout, result.StdoutTruncated = stdout.Tail()
+	result.Stderr, result.StderrTruncated = stderr.Tail()
+	c <- result
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mutex sync.Mutex
+	limit int
+	data []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.data = append(b.data, p...)
+	//trim only once twice the limit is buffered, so that each byte is copied a bounded number of times
+	if len(b.data) > 2 * b.limit {
+		b.data = append(b.data[:0], b.data[len(b.data) - b.limit:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// Tail returns the kept output and whether earlier output was dropped.
+func (b *tailBuffer) Tail() (string, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.data) > b.limit {
+		return string(b.data[len(b.data) - b.limit:]), true
+	}
+	return string(b.data), b.truncated
+}
@@ -0,0 +1,78 @@
+package cron
+
+import "math/bits"
+
+// bitset is the compiled form of a field, bit i is set when value i matches.
+// Next and Prev jump between set bits instead of scanning value lists, so computing a fire time never allocates.
+type bitset []uint64
+
+func newBitset(values []int) bitset {
+	b := bitset{}
+	for _, value := range values {
+		for len(b) <= value / 64 {
+			b = append(b, 0)
+		}
+		b[value / 64] |= 1 << uint(value % 64)
+	}
+	return b
+}
+
+func (b bitset) has(i int) bool {
+	return i >= 0 && i / 64 < len(b) && b[i / 64] & (1 << uint(i % 64)) != 0
+}
+
+// next returns the smallest value >= i in the set, false when there is none.
+func (b bitset) next(i int) (int, bool) {
+	if i < 0 {
+		i = 0
+	}
+	for word := i / 64; word < len(b); word++ {
+		w := b[word]
+		if word == i / 64 {
+			w &= ^uint64(0) << uint(i % 64)
+		}
+		if w != 0 {
+			return word * 64 + bits.TrailingZeros64(w), true
+		}
+	}
+	return 0, false
+}
+
+// prev returns the largest value <= i in the set, false when there is none.
+func (b bitset) prev(i int) (int, bool) {
+	if i < 0 {
+		return 0, false
+	}
+	word := i / 64
+	if word >= len(b) {
+		word = len(b) - 1
+		i = len(b) * 64 - 1
+	}
+	for ; word >= 0; word-- {
+		w := b[word]
+		if word == i / 64 {
+			w &= ^uint64(0) >> uint(63 - i % 64)
+		}
+		if w != 0 {
+			return word * 64 + 63 - bits.LeadingZeros64(w), true
+		}
+	}
+	return 0, false
+}
+
+func (b bitset) count() int {
+	n := 0
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// values returns the members of the set in ascending order.
+func (b bitset) values() []int {
+	values := []int{}
+	for i, ok := b.next(0); ok; i, ok = b.next(i + 1) {
+		values = append(values, i)
+	}
+	return values
+}
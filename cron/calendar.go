@@ -0,0 +1,324 @@
+package cron
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Calendar excludes fire times of the cron jobs it is attached to, like Quartz calendars.
+type Calendar interface {
+	// Excluded reports whether the fire time t must be skipped and, when it is, the time the exclusion ends.
+	Excluded(t time.Time) (bool, time.Time)
+}
+
+// HolidayCalendar excludes whole days, such as public holidays, time windows, such as change freezes, and
+// recurring events.
+type HolidayCalendar struct {
+	Dates map[string]bool //excluded days as 2006-01-02, on the wall clock of the fire time
+	Windows []Window //excluded time windows
+	Recurrences []Recurrence //excluded recurring events
+}
+
+// Window is the time span from Start, inclusive, to End, exclusive.
+type Window struct {
+	Start time.Time
+	End time.Time
+}
+
+// Recurrence is an event repeated at the occurrences of Rule, except the ones in Exceptions. Each occurrence lasts
+// Duration, or Days whole days when AllDay.
+type Recurrence struct {
+	Rule *RRuleExpression
+	Duration time.Duration
+	AllDay bool
+	Days int
+	Exceptions []time.Time //EXDATE date-times, the occurrences starting then are skipped
+	ExceptionDates map[string]bool //EXDATE dates as 2006-01-02, the occurrences starting that day are skipped
+}
+
+// maxOverlap bounds how many earlier occurrences Excluded looks at when occurrences last longer than their period.
+const maxOverlap = 366
+
+// end returns the time the occurrence starting at o ends.
+func (rc *Recurrence) end(o time.Time) time.Time {
+	if rc.AllDay {
+		return time.Date(o.Year(), o.Month(), o.Day()+rc.Days, 0, 0, 0, 0, o.Location())
+	}
+	return o.Add(rc.Duration)
+}
+
+func (rc *Recurrence) skipped(o time.Time) bool {
+	for _, x := range rc.Exceptions {
+		if o.Equal(x) {
+			return true
+		}
+	}
+	return rc.ExceptionDates[o.In(rc.Rule.location()).Format("2006-01-02")]
+}
+
+// covering returns the occurrence covering t and the time it ends.
+func (rc *Recurrence) covering(t time.Time) (bool, time.Time) {
+	//Prev is strict, look from just after t, or after the day of t for all-day events
+	probe := t.Add(time.Second)
+	if rc.AllDay {
+		w := t.In(rc.Rule.location())
+		probe = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, w.Location())
+	}
+	for i := 0; i < maxOverlap; i++ {
+		o, ok := rc.Rule.Prev(probe)
+		if !ok {
+			break
+		}
+		end := rc.end(o)
+		if !end.After(t) {
+			break
+		}
+		if !o.After(t) && !rc.skipped(o) {
+			return true, end
+		}
+		probe = o
+	}
+	return false, time.Time{}
+}
+
+// NewHolidayCalendar returns an empty HolidayCalendar.
+func NewHolidayCalendar() *HolidayCalendar {
+	return &HolidayCalendar{Dates: map[string]bool{}}
+}
+
+// AddDates excludes the days from start to end, both inclusive.
+func (hc *HolidayCalendar) AddDates(start, end time.Time) {
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC); !day.After(last); day = day.AddDate(0, 0, 1) {
+		hc.Dates[day.Format("2006-01-02")] = true
+	}
+}
+
+// AddWindow excludes the time from start, inclusive, to end, exclusive.
+func (hc *HolidayCalendar) AddWindow(start, end time.Time) {
+	hc.Windows = append(hc.Windows, Window{Start: start, End: end})
+}
+
+// AddRecurrence excludes the occurrences of a recurring event.
+func (hc *HolidayCalendar) AddRecurrence(rc Recurrence) {
+	hc.Recurrences = append(hc.Recurrences, rc)
+}
+
+func (hc *HolidayCalendar) Excluded(t time.Time) (bool, time.Time) {
+	if hc.Dates[t.Format("2006-01-02")] {
+		return true, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+	for _, w := range hc.Windows {
+		if !t.Before(w.Start) && t.Before(w.End) {
+			return true, w.End
+		}
+	}
+	for i := range hc.Recurrences {
+		if excluded, end := hc.Recurrences[i].covering(t); excluded {
+			return true, end
+		}
+	}
+	return false, time.Time{}
+}
+
+// CronCalendar excludes the times its expression fires at, e.g. * * 0-7,18-23 ? * * outside business hours.
+type CronCalendar struct {
+	Expression *CronExpression
+}
+
+func (cc *CronCalendar) Excluded(t time.Time) (bool, time.Time) {
+	t = t.Truncate(time.Second)
+	if next, ok := cc.Expression.Next(t.Add(-time.Second)); ok && next.Equal(t) {
+		return true, t.Add(time.Second)
+	}
+	return false, time.Time{}
+}
+
+// ParseCalendarFile is like TryParseCalendarFile but panics when the file cannot be read or parsed.
+func ParseCalendarFile(filepath string) *HolidayCalendar {
+	hc, err := TryParseCalendarFile(filepath)
+	if err != nil {
+		panic(err)
+	}
+	return hc
+}
+
+// TryParseCalendarFile reads a calendar file, see TryParseCalendarData.
+func TryParseCalendarFile(filepath string) (*HolidayCalendar, error) {
+	content, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("read calendar file error: %v", err)
+	}
+	return TryParseCalendarData(string(content))
+}
+
+var _regexDates = regexp.MustCompile(`^\s*(?P<start>\d{4}-\d{2}-\d{2})(\s*\.\.\s*(?P<end>\d{4}-\d{2}-\d{2}))?\s*$`) //eg: 2020-10-01..2020-10-07
+
+// TryParseCalendarData parses either an iCalendar file, whose VEVENTs are excluded with their RRULE and EXDATE
+// properties, or a list of excluded days with
+// one 2006-01-02 date or 2006-01-02..2006-01-07 range per line, skipping blank lines and # comments.
+func TryParseCalendarData(content string) (*HolidayCalendar, error) {
+	if strings.Contains(content, "BEGIN:VCALENDAR") {
+		return parseICalendar(content)
+	}
+	hc := NewHolidayCalendar()
+	for i, line := range regexp.MustCompile("\r?\n").Split(content, -1) {
+		if _regexSkipLine.MatchString(line) {
+			continue
+		}
+		match := _regexDates.FindStringSubmatch(line)
+		if match == nil {
+			return nil, &ParseError{Line: line, LineNo: i + 1, Reason: "line must be a date or a date range such as 2020-10-01..2020-10-07"}
+		}
+		start, err := time.Parse("2006-01-02", match[1])
+		if err != nil {
+			return nil, &ParseError{Line: line, LineNo: i + 1, Field: "date", Token: match[1], Reason: "invalid date"}
+		}
+		end := start
+		if match[3] != "" {
+			if end, err = time.Parse("2006-01-02", match[3]); err != nil {
+				return nil, &ParseError{Line: line, LineNo: i + 1, Field: "date", Token: match[3], Reason: "invalid date"}
+			}
+		}
+		hc.AddDates(start, end)
+	}
+	return hc, nil
+}
+
+var _regexICalProperty = regexp.MustCompile(`^(?P<name>[A-Z-]+)(?P<params>(;[^:]*)?):(?P<value>.*)$`) //eg: DTSTART;TZID=Asia/Shanghai:20201001T090000
+
+// icalEvent holds the properties of a VEVENT needed to exclude it.
+type icalEvent struct {
+	start, startParams string
+	end, endParams string
+	rule string
+	exdates [][2]string //value and parameters of each EXDATE
+	rdate bool
+}
+
+// parseICalendar excludes the VEVENTs of an iCalendar file, all-day events as days, other events as windows and
+// events with an RRULE as recurrences.
+func parseICalendar(content string) (*HolidayCalendar, error) {
+	hc := NewHolidayCalendar()
+	//unfold lines continued with a leading space or tab
+	content = regexp.MustCompile("\r?\n[ \t]").ReplaceAllString(content, "")
+	var event *icalEvent
+	for i, line := range regexp.MustCompile("\r?\n").Split(content, -1) {
+		match := _regexICalProperty.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		switch name, params, value := match[1], match[2], match[4]; {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &icalEvent{}
+		case event == nil:
+		case name == "END" && value == "VEVENT":
+			if err := hc.addEvent(event); err != nil {
+				return nil, &ParseError{Line: line, LineNo: i + 1, Field: "VEVENT", Token: event.start, Reason: err.Error()}
+			}
+			event = nil
+		case name == "DTSTART":
+			event.start, event.startParams = value, params
+		case name == "DTEND":
+			event.end, event.endParams = value, params
+		case name == "RRULE":
+			event.rule = value
+		case name == "EXDATE":
+			for _, exdate := range strings.Split(value, ",") {
+				event.exdates = append(event.exdates, [2]string{exdate, params})
+			}
+		case name == "RDATE":
+			event.rdate = true
+		}
+	}
+	return hc, nil
+}
+
+func (hc *HolidayCalendar) addEvent(event *icalEvent) error {
+	if event.start == "" {
+		return fmt.Errorf("event has no DTSTART")
+	}
+	if event.rdate {
+		return fmt.Errorf("RDATE is not supported")
+	}
+	from, allDay, err := parseICalTime(event.start, event.startParams, time.Local)
+	if err != nil {
+		return err
+	}
+	to := from.Add(time.Second)
+	if allDay {
+		to = from.AddDate(0, 0, 1)
+	}
+	if event.end != "" {
+		if to, _, err = parseICalTime(event.end, event.endParams, time.Local); err != nil {
+			return err
+		}
+	}
+	if event.rule != "" {
+		return hc.addRecurringEvent(event, from, to, allDay)
+	}
+	if allDay {
+		//DTEND of an all-day event is the first day after it
+		hc.AddDates(from, to.AddDate(0, 0, -1))
+	} else {
+		hc.AddWindow(from, to)
+	}
+	return nil
+}
+
+func (hc *HolidayCalendar) addRecurringEvent(event *icalEvent, from, to time.Time, allDay bool) error {
+	rule, err := (&Parser{Location: time.Local}).ParseRRule("DTSTART" + event.startParams + ":" + event.start + " RRULE:" + event.rule)
+	if err != nil {
+		return err
+	}
+	rc := Recurrence{Rule: rule, Duration: to.Sub(from), AllDay: allDay, ExceptionDates: map[string]bool{}}
+	if allDay {
+		rc.Days = int(to.Sub(from).Hours() / 24 + 0.5)
+	}
+	for _, exdate := range event.exdates {
+		x, date, err := parseICalTime(exdate[0], exdate[1], rule.location())
+		if err != nil {
+			return fmt.Errorf("invalid EXDATE %s: %v", exdate[0], err)
+		}
+		if date {
+			rc.ExceptionDates[x.Format("2006-01-02")] = true
+		} else {
+			rc.Exceptions = append(rc.Exceptions, x)
+		}
+	}
+	hc.AddRecurrence(rc)
+	return nil
+}
+
+// parseICalTime parses a DATE or DATE-TIME value, DATE-TIMEs are UTC with a Z suffix, in the TZID parameter's zone
+// or else in loc.
+func parseICalTime(value, params string, loc *time.Location) (time.Time, bool, error) {
+	if len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	} else if zone, err := tzidLocation(params); err != nil {
+		return time.Time{}, false, err
+	} else if zone != nil {
+		loc = zone
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var _regexTZID = regexp.MustCompile(`;TZID=([^;:]+)`) //eg: ;TZID=Asia/Shanghai
+
+// tzidLocation returns the zone of the TZID parameter, nil when there is none.
+func tzidLocation(params string) (*time.Location, error) {
+	tzid := _regexTZID.FindStringSubmatch(params)
+	if tzid == nil {
+		return nil, nil
+	}
+	return time.LoadLocation(strings.Trim(tzid[1], `"`))
+}
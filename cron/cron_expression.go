@@ -2,6 +2,7 @@ package cron
 
 import (
 	"fmt"
+	"hash/fnv"
 	"regexp"
 	"sort"
 	"strconv"
@@ -14,7 +15,7 @@ import (
 -    -    -    -    -    -    -
 |    |    |    |    |    |    |
 |    |    |    |    |    |    |
-|    |    |    |    |    |    +--------- year(当前年份-当前年份+100年) 支持, - * /四种特殊字符
+|    |    |    |    |    |    +--------- year(1970-2199) 支持, - * /四种特殊字符
 |    |    |    |    |    +-------------- day of week(1-7) 支持,- * ? / L W七种特殊字符
 |    |    |    |    +------------------- month(1-12) 支持, - * /四种特殊字符
 |    |    |    +------------------------ day of month(1-31) 支持,- * ? / L #七种特殊字符
@@ -33,6 +34,9 @@ type CronExpression struct {
 	Minute int
 	Second int
 	IsEnd bool
+	Reboot bool //@reboot, runs once when the scheduler starts and never fires afterwards
+	Location *time.Location //time zone the fields are evaluated in, nil means time.Local
+	// Deprecated: the MoveNext and Check functions walk the fields of the current time one by one, use Next.
 	MoveNextYear func() time.Time
 	MoveNextMonth func() time.Time
 	MoveNextDay func() time.Time
@@ -45,13 +49,85 @@ type CronExpression struct {
 	CheckHour func() bool
 	CheckMinute func() bool
 	CheckSecond func() bool
+	mode Mode
+	seconds bitset
+	minutes bitset
+	hours bitset
+	months bitset
+	years bitset
+	dayOfMonth func(t time.Time) bool //nil when the field is ?
+	dayOfWeek func(t time.Time) bool //nil when the field is ?
+	dayOr bool //unix mode, a day matches when either day field matches
+	daysOfMonth bitset //nil when the field is ? or a special token
+	daysOfWeek bitset //SUN=1, nil when the field is ? or a special token
+	dayOfMonthToken string //special day of month token such as L-3 or 15W
+	dayOfWeekToken string //special day of week token such as 6L or 6#3
 }
 
+// ParseError describes why a cron expression or a cron job line was rejected.
+type ParseError struct {
+	Line   string // the expression or cron file line being parsed
+	LineNo int    // 1-based line number in a cron file, 0 when unknown
+	Field  string // second, minute, hour, dayofmonth, month, dayofweek, year, interval, anchor, a job attribute such as id or "" for the whole line
+	Token  string // the offending token
+	Min    int    // allowed range of the field, only meaningful when Min < Max
+	Max    int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	msg := "cron: "
+	if e.LineNo > 0 {
+		msg += fmt.Sprintf("line %d: ", e.LineNo)
+	}
+	if e.Field == "" {
+		msg += fmt.Sprintf("%s: %q", e.Reason, e.Line)
+	} else {
+		msg += fmt.Sprintf("invalid %s %q in %q: %s", e.Field, e.Token, e.Line, e.Reason)
+	}
+	if e.Min < e.Max {
+		msg += fmt.Sprintf(" (allowed %d-%d)", e.Min, e.Max)
+	}
+	return msg
+}
+
+// ParseCronExpression is like TryParseCronExpression but panics when the expression is invalid.
 func ParseCronExpression(line string) *CronExpression {
-	regexLine := regexp.MustCompile(`^(?P<second>(.*?))\s+(?P<minute>(.*?))\s+(?P<hour>(.*?))\s+(?P<dayofmonth>(.*?))\s+(?P<month>(.*?))\s+(?P<dayofweek>(.*?))(\s+(?P<year>([0-9\-\*,]+)))?$`)
+	ce, err := TryParseCronExpression(line)
+	if err != nil {
+		panic(err)
+	}
+	return ce
+}
+
+// TryParseCronExpression parses a six or seven field cron expression or one of the @yearly, @annually, @monthly,
+// @weekly, @daily, @midnight, @hourly and @reboot macros, returning a *ParseError when it is invalid.
+func TryParseCronExpression(line string) (*CronExpression, error) {
+	return (&Parser{}).ParseCronExpression(line)
+}
+
+// ParseCronExpression parses a cron expression in the parser's Mode or one of the @yearly, @annually, @monthly,
+// @weekly, @daily, @midnight, @hourly and @reboot macros, returning a *ParseError when it is invalid.
+// A CRON_TZ=<zone> or TZ=<zone> prefix evaluates the expression in that IANA time zone instead of the parser's Location.
+func (p *Parser) ParseCronExpression(line string) (*CronExpression, error) {
+	p, line, err := p.splitZone(line)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(line, "@") {
+		return p.parseMacro(line)
+	}
+	if p.Mode == UnixMode {
+		return p.parseUnix(line)
+	}
+	return p.parseQuartz(line)
+}
+
+func (p *Parser) parseQuartz(line string) (*CronExpression, error) {
+	regexLine := regexp.MustCompile(`^(?P<second>(.*?))\s+(?P<minute>(.*?))\s+(?P<hour>(.*?))\s+(?P<dayofmonth>(.*?))\s+(?P<month>(.*?))\s+(?P<dayofweek>(.*?))(\s+(?P<year>([0-9H()\-\*,/]+)))?$`)
 	match := regexLine.FindStringSubmatch(line)
 	if match == nil {
-		panic(line)
+		return nil, &ParseError{Line: line, Reason: "expression must have 6 or 7 fields"}
 	}
 	result := make(map[string]string)
 	groupNames := regexLine.SubexpNames()
@@ -64,73 +140,350 @@ func ParseCronExpression(line string) *CronExpression {
 		result["year"] = "*"
 	}
 	if result["dayofmonth"] != "?" && result["dayofweek"] != "?" {
-		panic(line)
+		return nil, &ParseError{Line: line, Field: "dayofmonth", Token: result["dayofmonth"],
+			Reason: "one of day of month and day of week must be '?'"}
 	}
+	return p.parseFields(line, result, _fieldNames)
+}
 
-	now := time.Now()
+// parseUnix parses a Vixie cron "minute hour dayofmonth month dayofweek" expression where 0 and 7 are Sunday,
+// a day matches either day field unless one of them starts with *.
+func (p *Parser) parseUnix(line string) (*CronExpression, error) {
+	regexLine := regexp.MustCompile(`^(?P<minute>\S+)\s+(?P<hour>\S+)\s+(?P<unixdayofmonth>\S+)\s+(?P<month>\S+)\s+(?P<unixdayofweek>\S+)$`)
+	match := regexLine.FindStringSubmatch(line)
+	if match == nil {
+		return nil, &ParseError{Line: line, Reason: "expression must have 5 fields"}
+	}
+	result := map[string]string{"second": "0", "year": "*"}
+	groupNames := regexLine.SubexpNames()
+	for i, name := range groupNames {
+		if i != 0 && name != "" {
+			result[name] = match[i]
+		}
+	}
+	ce, err := p.parseFields(line, result, _unixFieldNames)
+	if err != nil {
+		return nil, err
+	}
+	ce.mode = UnixMode
+	//like Vixie cron, a day field starting with * makes both fields apply together
+	ce.dayOr = !strings.HasPrefix(result["unixdayofmonth"], "*") && !strings.HasPrefix(result["unixdayofweek"], "*")
+	//keep */n day tokens, their leading * is significant when rendering the expression
+	if strings.HasPrefix(result["unixdayofmonth"], "*/") && ce.daysOfMonth.count() != 31 {
+		ce.dayOfMonthToken = result["unixdayofmonth"]
+	}
+	if strings.HasPrefix(result["unixdayofweek"], "*/") && ce.daysOfWeek.count() != 7 {
+		ce.dayOfWeekToken = result["unixdayofweek"]
+	}
+	return ce, nil
+}
+
+func (p *Parser) parseFields(line string, result map[string]string, fieldNames []string) (*CronExpression, error) {
+	now := time.Now().In(p.location())
 	ce := &CronExpression{Second:now.Second(),Minute:now.Minute(),Hour:now.Hour(),Day:now.Day(),Month:int(now.Month()),Year:now.Year(),IsEnd:false}
-	for k, v := range result {
+	ce.Location = p.location()
+	for _, k := range fieldNames {
+		token := result[k]
+		v := ReplaceHash(k, ReplaceNames(k, token), p.Key)
 		flag := false
 		for _, r := range _cronPatternCheck[k] {
 			if r.MatchString(v) {
 				if _cronValueCheck[r](k, v) == false {
-					panic(v)
+					if r == _regexLOnly || r == _regexLW {
+						return nil, &ParseError{Line: line, Field: k, Token: token, Min: 1, Max: maxLastDayOffset(v),
+							Reason: "offset out of range"}
+					}
+					return nil, &ParseError{Line: line, Field: strings.TrimPrefix(k, "unix"), Token: token,
+						Min: _timeRange[k][0], Max: _timeRange[k][1], Reason: "value out of range"}
 				}
 				if v != "?" {
-					switch k {
-					case "second":
-						ce.MoveNextSecond, ce.CheckSecond = _cronFunc[r](ce, k, v)
-					case "minute":
-						ce.MoveNextMinute, ce.CheckMinute = _cronFunc[r](ce, k, v)
-					case "hour":
-						ce.MoveNextHour, ce.CheckHour = _cronFunc[r](ce, k, v)
-					case "dayofweek", "dayofmonth":
-						ce.MoveNextDay, ce.CheckDay = _cronFunc[r](ce, k, v)
-					case "month":
-						ce.MoveNextMonth, ce.CheckMonth = _cronFunc[r](ce, k, v)
-					case "year":
-						ce.MoveNextYear, ce.CheckYear = _cronFunc[r](ce, k, v)
-					}
+					ce.setField(k, r, v)
 				}
 				flag = true
 				break
 			}
 		}
 		if !flag {
-			panic(fmt.Sprintf("[expression:%s, unknown:%s]", line, v))
+			reason := "unsupported syntax"
+			if k == "year" && strings.Contains(v, "H") {
+				reason = "H in the year field needs a range such as H(2030-2040)"
+			} else if strings.Contains(v, "H") {
+				reason = "H must be a whole list item such as H, H(0-29), H/15 or H(0-29)/10"
+			}
+			return nil, &ParseError{Line: line, Field: strings.TrimPrefix(k, "unix"), Token: token, Reason: reason}
 		}
 	}
-	return ce
+	ce.setLegacyFuncs()
+	return ce, nil
+}
+
+var _macros = map[string]string {
+	"@yearly": "0 0 0 1 1 ? *",
+	"@annually": "0 0 0 1 1 ? *",
+	"@monthly": "0 0 0 1 * ? *",
+	"@weekly": "0 0 0 ? * 1 *",
+	"@daily": "0 0 0 * * ? *",
+	"@midnight": "0 0 0 * * ? *",
+	"@hourly": "0 0 * * * ? *",
+}
+
+// _unixMacros are the macros in UnixMode, so that the expressions keep rendering as 5 fields.
+var _unixMacros = map[string]string {
+	"@yearly": "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly": "0 0 * * 0",
+	"@daily": "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly": "0 * * * *",
+}
+
+func (p *Parser) parseMacro(line string) (*CronExpression, error) {
+	macro := strings.ToLower(line)
+	if macro == "@reboot" {
+		ce := &CronExpression{Reboot: true, Location: p.location()}
+		ce.setLegacyFuncs()
+		return ce, nil
+	}
+	if p.Mode == UnixMode {
+		if expression, exists := _unixMacros[macro]; exists {
+			return p.parseUnix(expression)
+		}
+	}
+	expression, exists := _macros[macro]
+	if !exists {
+		return nil, &ParseError{Line: line, Reason: "unknown macro"}
+	}
+	return p.parseQuartz(expression)
+}
+
+func (ce *CronExpression) setField(timePart string, r *regexp.Regexp, match string) {
+	if f, exists := _cronDayFunc[r]; exists {
+		if timePart == "dayofmonth" {
+			ce.dayOfMonth = f(timePart, match)
+			ce.dayOfMonthToken = match
+		} else {
+			ce.dayOfWeek = f(timePart, match)
+			ce.dayOfWeekToken = match
+		}
+		return
+	}
+	vals := _cronValues[r](timePart, match)
+	set := newBitset(vals)
+	switch timePart {
+	case "second":
+		ce.seconds = set
+	case "minute":
+		ce.minutes = set
+	case "hour":
+		ce.hours = set
+	case "dayofmonth", "unixdayofmonth":
+		ce.dayOfMonth = func(t time.Time) bool { return set.has(t.Day()) }
+		ce.daysOfMonth = set
+	case "month":
+		ce.months = set
+	case "dayofweek":
+		ce.dayOfWeek = func(t time.Time) bool { return set.has(int(t.Weekday())+1) }
+		ce.daysOfWeek = set
+	case "unixdayofweek":
+		days := []int{}
+		for day := 1; day <= 7; day++ {
+			if contains(vals, day-1) || (day == 1 && contains(vals, 7)) {
+				days = append(days, day)
+			}
+		}
+		weekdays := newBitset(days)
+		ce.dayOfWeek = func(t time.Time) bool { return weekdays.has(int(t.Weekday())+1) }
+		ce.daysOfWeek = weekdays
+	case "year":
+		ce.years = set
+	}
 }
 
+// MoveNext advances the expression to its next fire time after ToTime() and returns it,
+// IsEnd is set once no later fire time exists.
 func (ce *CronExpression) MoveNext() time.Time {
 	if ce.IsEnd {
 		return ce.ToTime()
 	}
-	now := ce.ToTime()
-	if !ce.CheckSecond() || ce.ToTime().Unix() <= now.Unix() {
-		ce.MoveNextSecond()
+	next, ok := ce.Next(ce.ToTime())
+	if !ok {
+		ce.IsEnd = true
+		return ce.ToTime()
 	}
-	if !ce.CheckMinute() || ce.ToTime().Unix() <= now.Unix() {
-		ce.MoveNextMinute()
+	ce.SetTime(next)
+	return next
+}
+
+// Next returns the first fire time strictly after t, false when the expression never fires again.
+// It does not modify the expression and is safe for concurrent use.
+//
+// Fields are matched against the wall clock of the expression's Location. A wall clock time skipped by a daylight
+// saving transition fires once at the transition, a wall clock time repeated by a transition fires only at its
+// first occurrence.
+func (ce *CronExpression) Next(t time.Time) (time.Time, bool) {
+	if ce.Reboot {
+		return time.Time{}, false
 	}
-	if !ce.CheckHour() || ce.ToTime().Unix() <= now.Unix() {
-		ce.MoveNextHour()
+	loc := ce.location()
+	after := t
+	w := toWall(t.Truncate(time.Second), loc).Add(time.Second)
+	for {
+		year, ok := ce.years.next(w.Year())
+		if !ok {
+			break
+		}
+		if year != w.Year() {
+			w = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		if month, ok := ce.months.next(int(w.Month())); !ok {
+			w = time.Date(w.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		} else if month != int(w.Month()) {
+			w = time.Date(w.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		}
+		if !ce.checkDay(w) {
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if hour, ok := ce.hours.next(w.Hour()); !ok {
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		} else if hour != w.Hour() {
+			w = time.Date(w.Year(), w.Month(), w.Day(), hour, 0, 0, 0, time.UTC)
+		}
+		if minute, ok := ce.minutes.next(w.Minute()); !ok {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		} else if minute != w.Minute() {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), minute, 0, 0, time.UTC)
+		}
+		if second, ok := ce.seconds.next(w.Second()); !ok {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute()+1, 0, 0, time.UTC)
+			continue
+		} else if second != w.Second() {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), second, 0, time.UTC)
+		}
+		if next := fromWall(w, loc); next.After(after) {
+			return next, true
+		}
+		w = w.Add(time.Second)
 	}
-	if !ce.CheckDay() || ce.ToTime().Unix() <= now.Unix() {
-		ce.MoveNextDay()
+	return time.Time{}, false
+}
+
+// Prev returns the last fire time strictly before t, false when the expression never fired before.
+// It does not modify the expression, is safe for concurrent use and follows the same daylight saving rules as Next.
+func (ce *CronExpression) Prev(t time.Time) (time.Time, bool) {
+	if ce.Reboot {
+		return time.Time{}, false
 	}
-	if !ce.CheckMonth() || ce.ToTime().Unix() <= now.Unix() {
-		ce.MoveNextMonth()
+	loc := ce.location()
+	before := t
+	if truncated := t.Truncate(time.Second); truncated.Equal(t) {
+		t = t.Add(-time.Second)
+	} else {
+		t = truncated
 	}
-	if !ce.CheckYear() || ce.ToTime().Unix() <= now.Unix() {
-		ce.MoveNextYear()
+	w := toWall(t, loc)
+	//inside a repeated hour, wall clock times after t's may have fired at their first occurrence
+	if earlier := toWall(t.Add(-3 * time.Hour), loc).Add(3 * time.Hour); earlier.After(w) {
+		w = earlier
 	}
-	if ce.ToTime().Unix() <= now.Unix() {
-		ce.IsEnd = true
-		ce.SetTime(now)
+	for {
+		year, ok := ce.years.prev(w.Year())
+		if !ok {
+			break
+		}
+		if year != w.Year() {
+			w = time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+		}
+		if month, ok := ce.months.prev(int(w.Month())); !ok {
+			w = time.Date(w.Year(), 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+			continue
+		} else if month != int(w.Month()) {
+			w = time.Date(w.Year(), time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+		}
+		if !ce.checkDay(w) {
+			w = time.Date(w.Year(), w.Month(), w.Day(), 0, 0, 0, 0, time.UTC).Add(-time.Second)
+			continue
+		}
+		if hour, ok := ce.hours.prev(w.Hour()); !ok {
+			w = time.Date(w.Year(), w.Month(), w.Day(), 0, 0, 0, 0, time.UTC).Add(-time.Second)
+			continue
+		} else if hour != w.Hour() {
+			w = time.Date(w.Year(), w.Month(), w.Day(), hour, 59, 59, 0, time.UTC)
+		}
+		if minute, ok := ce.minutes.prev(w.Minute()); !ok {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), 0, 0, 0, time.UTC).Add(-time.Second)
+			continue
+		} else if minute != w.Minute() {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), minute, 59, 0, time.UTC)
+		}
+		if second, ok := ce.seconds.prev(w.Second()); !ok {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, time.UTC).Add(-time.Second)
+			continue
+		} else if second != w.Second() {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), second, 0, time.UTC)
+		}
+		if prev := fromWall(w, loc); prev.Before(before) {
+			return prev, true
+		}
+		w = w.Add(-time.Second)
 	}
-	return ce.ToTime()
+	return time.Time{}, false
+}
+
+// toWall returns the wall clock of t in loc as a UTC time, so that calendar arithmetic on it never meets a
+// daylight saving transition.
+func toWall(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// fromWall returns the instant at which the wall clock of loc shows w. When the wall clock shows w twice the first
+// instant is returned, when w was skipped the instant of the transition is returned.
+func fromWall(w time.Time, loc *time.Location) time.Time {
+	u := w.Unix()
+	_, early := time.Unix(u - 86400, 0).In(loc).Zone()
+	_, late := time.Unix(u + 86400, 0).In(loc).Zone()
+	found := false
+	result := time.Time{}
+	for _, offset := range []int{early, late} {
+		t := time.Unix(u - int64(offset), 0).In(loc)
+		if _, o := t.Zone(); o == offset && (!found || t.Before(result)) {
+			found = true
+			result = t
+		}
+	}
+	if found {
+		return result
+	}
+	//w falls into a gap, search the first instant whose wall clock is past w
+	lo := u - int64(late)
+	hi := u - int64(early)
+	for lo < hi {
+		mid := lo + (hi - lo) / 2
+		if !toWall(time.Unix(mid, 0), loc).Before(w) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return time.Unix(lo, 0).In(loc)
+}
+
+func (ce *CronExpression) checkDay(t time.Time) bool {
+	if ce.dayOr {
+		return ce.dayOfMonth(t) || ce.dayOfWeek(t)
+	}
+	if ce.dayOfMonth != nil && !ce.dayOfMonth(t) {
+		return false
+	}
+	if ce.dayOfWeek != nil && !ce.dayOfWeek(t) {
+		return false
+	}
+	return true
 }
 
 func (ce *CronExpression) GetValue(timePart string) int {
@@ -151,48 +504,8 @@ func (ce *CronExpression) GetValue(timePart string) int {
 	panic(timePart)
 }
 
-func (ce *CronExpression) SetValue(timePart string, val int) *CronExpression {
-	switch timePart {
-	case "second":
-		ce.Second = val
-	case "minute":
-		ce.Minute = val
-		ce.SetValue("second",0)
-		if !ce.CheckMinute() {
-			ce.MoveNextSecond()
-		}
-	case "hour":
-		ce.Hour = val
-		ce.SetValue("minute",0)
-		if !ce.CheckMinute() {
-			ce.MoveNextMinute()
-		}
-	case "dayofweek","dayofmonth","day":
-		ce.Day = val
-		ce.SetValue("hour",0)
-		if !ce.CheckHour() {
-			ce.MoveNextHour()
-		}
-	case "month":
-		ce.Month = val
-		ce.SetValue("day",1)
-		if !ce.CheckDay() {
-			ce.MoveNextDay()
-		}
-		if !ce.CheckDay() {
-			ce.MoveNextMonth()
-		}
-	case "year":
-		ce.Year = val
-		ce.SetValue("month",1)
-		if !ce.CheckMonth() {
-			ce.MoveNextMonth()
-		}
-	}
-	return ce
-}
-
 func (ce *CronExpression) SetTime(t time.Time) *CronExpression {
+	t = t.In(ce.location())
 	ce.Year = t.Year()
 	ce.Month = int(t.Month())
 	ce.Day = t.Day()
@@ -203,28 +516,135 @@ func (ce *CronExpression) SetTime(t time.Time) *CronExpression {
 }
 
 func (ce *CronExpression) ToTime() time.Time {
-	return time.Date(ce.Year,time.Month(ce.Month),ce.Day,ce.Hour,ce.Minute,ce.Second,0,time.Local)
+	return fromWall(time.Date(ce.Year,time.Month(ce.Month),ce.Day,ce.Hour,ce.Minute,ce.Second,0,time.UTC), ce.location())
+}
+
+func (ce *CronExpression) location() *time.Location {
+	if ce.Location == nil {
+		return time.Local
+	}
+	return ce.Location
 }
 
 var _regexStar = regexp.MustCompile(`^\*$`) //eg: *
 var _regexArea = regexp.MustCompile(`^([0-9]+)-([0-9]+)$`) //eg: 20-30
 var _regexSlice = regexp.MustCompile(`^(([0-9]+)|\*)/[0-9]+$`) //eg: */10
 var _regexAreaSlice = regexp.MustCompile(`^([0-9]+)-([0-9]+)/[0-9]+$`) //eg: 10-30/2
-var _regexEnum = regexp.MustCompile(`^([0-9]+)(,[0-9]+)*$`) //eg: 10,20,30,40
+var _regexNumber = regexp.MustCompile(`^[0-9]+$`) //eg: 10
+var _regexEnum = regexp.MustCompile(`^(\*|[0-9]+(-[0-9]+)?)(/[0-9]+)?(,(\*|[0-9]+(-[0-9]+)?)(/[0-9]+)?)*$`) //eg: 10,20,30,40 or 8-10,12,14-18/2
+var _enumItems = []*regexp.Regexp{_regexStar, _regexNumber, _regexArea, _regexSlice, _regexAreaSlice}
 var _regexL = regexp.MustCompile(`^[1-7]?L$`) //eg: 4L
-var _regexLOnly = regexp.MustCompile(`^L$`)
-var _regexLW = regexp.MustCompile(`^LW$`) //eg: LW
+var _regexLOnly = regexp.MustCompile(`^L(-[0-9]+)?$`) //eg: L, L-3
+var _regexLW = regexp.MustCompile(`^(L(-[0-9]+)?W|LW-[0-9]+)$`) //eg: LW, L-3W, LW-2
+var _regexW = regexp.MustCompile(`^[0-9]+W$`) //eg: 15W
 var _regexIgnore = regexp.MustCompile(`^\?$`) //eg: ?
 var _regexWeekDay = regexp.MustCompile(`^[1-7]#[1-5]$`) //eg:  3#2
 
+var _regexName = regexp.MustCompile(`(?i)JAN|FEB|MAR|APR|MAY|JUN|JUL|AUG|SEP|OCT|NOV|DEC|SUN|MON|TUE|WED|THU|FRI|SAT`) //eg: MON-FRI
+
+var _nameValues = map[string]map[string]string {
+	"month": map[string]string {
+		"JAN": "1", "FEB": "2", "MAR": "3", "APR": "4", "MAY": "5", "JUN": "6",
+		"JUL": "7", "AUG": "8", "SEP": "9", "OCT": "10", "NOV": "11", "DEC": "12",
+	},
+	"unixdayofweek": map[string]string {
+		"SUN": "0", "MON": "1", "TUE": "2", "WED": "3", "THU": "4", "FRI": "5", "SAT": "6",
+	},
+	"dayofweek": map[string]string {
+		"SUN": "1", "MON": "2", "TUE": "3", "WED": "4", "THU": "5", "FRI": "6", "SAT": "7",
+	},
+}
+
+// ReplaceNames turns the case-insensitive month (JAN-DEC) and day of week (SUN-SAT) names of a field into numbers,
+// names that do not belong to the field are kept so that they are reported as invalid.
+func ReplaceNames(timePart string, match string) string {
+	names, exists := _nameValues[timePart]
+	if !exists {
+		return match
+	}
+	return _regexName.ReplaceAllStringFunc(match, func(name string) string {
+		if num, exists := names[strings.ToUpper(name)]; exists {
+			return num
+		}
+		return name
+	})
+}
+
+var _regexHash = regexp.MustCompile(`^H(\(([0-9]+)-([0-9]+)\))?(/([0-9]+))?$`) //eg: H, H(0-29), H/15, H(0-29)/10
+
+// _hashRange narrows the values H picks from, days of month stop at 28 so that every month has them.
+var _hashRange = map[string][]int {
+	"dayofmonth": []int { 1, 28},
+	"unixdayofmonth": []int { 1, 28},
+	"unixdayofweek": []int { 0, 6},
+}
+
+// ReplaceHash turns the H list items of a field into numbers derived from key, so that jobs written alike spread over
+// the field while each keeps the same value across restarts: H picks a value, H(a-b) a value between a and b,
+// H/n and H(a-b)/n a starting point for every n. The year field only takes H(a-b) and H(a-b)/n, as a year hashed
+// from the whole window could be in the past. Items it cannot replace, such as 1H, are left for the parser to reject.
+func ReplaceHash(timePart string, match string, key string) string {
+	min := _timeRange[timePart][0]
+	max := _timeRange[timePart][1]
+	if r, exists := _hashRange[timePart]; exists {
+		min, max = r[0], r[1]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(timePart + "\x00" + key))
+	hash := int(h.Sum32() & 0x7fffffff)
+	items := strings.Split(match, ",")
+	for i, item := range items {
+		items[i] = replaceHashItem(timePart, item, hash, min, max)
+	}
+	return strings.Join(items, ",")
+}
+
+func replaceHashItem(timePart string, token string, hash int, min, max int) string {
+	parts := _regexHash.FindStringSubmatch(token)
+	if parts == nil || timePart == "year" && parts[1] == "" {
+		return token
+	}
+	start, end := min, max
+	if parts[1] != "" {
+		start, _ = strconv.Atoi(parts[2])
+		end, _ = strconv.Atoi(parts[3])
+		if start > end {
+			return token
+		}
+		if start < _timeRange[timePart][0] || end > _timeRange[timePart][1] {
+			return fmt.Sprintf("%d-%d", start, end) //reported as out of range
+		}
+	}
+	if parts[4] == "" {
+		return strconv.Itoa(start + hash % (end - start + 1))
+	}
+	step, _ := strconv.Atoi(parts[5])
+	if step <= 0 {
+		return token
+	}
+	width := end - start + 1
+	if step < width {
+		width = step
+	}
+	if parts[1] == "" {
+		return fmt.Sprintf("%d/%d", start + hash % width, step)
+	}
+	return fmt.Sprintf("%d-%d/%d", start + hash % width, end, step)
+}
+
+var _fieldNames = []string{"second", "minute", "hour", "dayofmonth", "month", "dayofweek", "year"}
+var _unixFieldNames = []string{"second", "minute", "hour", "unixdayofmonth", "month", "unixdayofweek", "year"}
+
 var _cronPatternCheck = map[string][]*regexp.Regexp {
 	"second": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
 	"minute": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
 	"hour": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
-	"dayofmonth": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum, _regexLW , _regexLOnly, _regexIgnore},
+	"dayofmonth": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum, _regexLW , _regexLOnly, _regexW, _regexIgnore},
 	"month": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
 	"dayofweek": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum, _regexL, _regexIgnore, _regexWeekDay},
 	"year": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
+	"unixdayofmonth": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
+	"unixdayofweek": []*regexp.Regexp { _regexStar, _regexArea, _regexSlice, _regexAreaSlice, _regexEnum},
 }
 
 var _timeRange = map[string][]int {
@@ -234,7 +654,9 @@ var _timeRange = map[string][]int {
 	"dayofmonth": []int { 1, 31},
 	"month": []int { 1, 12},
 	"dayofweek": []int { 1, 7},
-	"year": []int { time.Now().Year(), time.Now().Year() + 100},
+	"unixdayofmonth": []int { 1, 31},
+	"unixdayofweek": []int { 0, 7},
+	"year": []int { 1970, 2199}, //fixed like Quartz, so expressions stay valid however long the scheduler runs
 }
 
 var _cronValueCheck = map[*regexp.Regexp]func(timePart string, match string) bool {
@@ -254,10 +676,10 @@ var _cronValueCheck = map[*regexp.Regexp]func(timePart string, match string) boo
 		min := _timeRange[timePart][0]
 		max := _timeRange[timePart][1]
 		if start == "*" {
-			return !(slice < min || slice > max)
+			return validStep(timePart, slice)
 		} else {
 			iStart, _ := strconv.Atoi(start)
-			return !(iStart < min || iStart > max || slice < min || slice > max)
+			return !(iStart < min || iStart > max) && validStep(timePart, slice)
 		}
 	},
 	_regexAreaSlice: func(timePart string, match string) bool {
@@ -268,18 +690,11 @@ var _cronValueCheck = map[*regexp.Regexp]func(timePart string, match string) boo
 		slice, _  := strconv.Atoi(parts[1])
 		min := _timeRange[timePart][0]
 		max := _timeRange[timePart][1]
-		return !(start < min || start > max || end < min || end > max || slice < min || slice > max)
+		return !(start < min || start > max || end < min || end > max) && validStep(timePart, slice)
 	},
-	_regexEnum: func(timePart string, match string) bool {
-		min := _timeRange[timePart][0]
-		max := _timeRange[timePart][1]
-		for _, enum := range strings.Split(match, ",") {
-			num, _ := strconv.Atoi(enum)
-			if num < min || num > max {
-				return false
-			}
-		}
-		return true
+	_regexNumber: func(timePart string, match string) bool {
+		num, _ := strconv.Atoi(match)
+		return _timeRange[timePart][0] <= num && num <= _timeRange[timePart][1]
 	},
 	_regexL: func(timePart string, match string) bool {
 		parts := strings.Split(match, "L")
@@ -292,10 +707,16 @@ var _cronValueCheck = map[*regexp.Regexp]func(timePart string, match string) boo
 		return !(num < min || num > max)
 	},
 	_regexLOnly: func(timePart string, match string) bool {
-		return true
+		offset := lastDayOffset(match)
+		return !strings.Contains(match, "-") || 1 <= offset && offset <= maxLastDayOffset(match)
 	},
 	_regexLW: func(timePart string, match string) bool {
-		return true
+		offset := lastDayOffset(match)
+		return !strings.Contains(match, "-") || 1 <= offset && offset <= maxLastDayOffset(match)
+	},
+	_regexW: func(timePart string, match string) bool {
+		num, _ := strconv.Atoi(strings.TrimSuffix(match, "W"))
+		return _timeRange[timePart][0] <= num && num <= _timeRange[timePart][1]
 	},
 	_regexIgnore: func(timePart string, match string) bool {
 		return true
@@ -308,15 +729,13 @@ var _cronValueCheck = map[*regexp.Regexp]func(timePart string, match string) boo
 	},
 }
 
-var _cronFunc = map[*regexp.Regexp]func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
-	_regexStar: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
+var _cronValues = map[*regexp.Regexp]func(timePart string, match string) []int {
+	_regexStar: func(timePart string, match string) []int {
 		min := _timeRange[timePart][0]
 		max := _timeRange[timePart][1]
-		slices := Slice(min, max, min, max, 1)
-		sort.Ints(slices)
-		return CreateMoveFunc(ce, timePart, slices),func() bool { return true }
+		return Slice(min, max, min, max, 1)
 	},
-	_regexArea: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
+	_regexArea: func(timePart string, match string) []int {
 		areas := strings.Split(match, "-")
 		start, _ := strconv.Atoi(areas[0])
 		end, _ := strconv.Atoi(areas[1])
@@ -324,9 +743,9 @@ var _cronFunc = map[*regexp.Regexp]func(ce *CronExpression, timePart string, mat
 		max := _timeRange[timePart][1]
 		slices := Slice(start, end, min, max, 1)
 		sort.Ints(slices)
-		return CreateMoveFunc(ce, timePart, slices), CreateCheckFunc(ce,timePart,slices)
+		return slices
 	},
-	_regexSlice: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
+	_regexSlice: func(timePart string, match string) []int {
 		parts := strings.Split(match, "/")
 		start := parts[0]
 		slice, _  := strconv.Atoi(parts[1])
@@ -340,9 +759,9 @@ var _cronFunc = map[*regexp.Regexp]func(ce *CronExpression, timePart string, mat
 			slices = Slice(iStart, max, min, max, slice)
 		}
 		sort.Ints(slices)
-		return CreateMoveFunc(ce, timePart, slices), CreateCheckFunc(ce,timePart,slices)
+		return slices
 	},
-	_regexAreaSlice: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
+	_regexAreaSlice: func(timePart string, match string) []int {
 		parts := strings.Split(match, "/")
 		areas := strings.Split(parts[0], "-")
 		start, _ := strconv.Atoi(areas[0])
@@ -352,230 +771,168 @@ var _cronFunc = map[*regexp.Regexp]func(ce *CronExpression, timePart string, mat
 		max := _timeRange[timePart][1]
 		slices := Slice(start, end, min, max, slice)
 		sort.Ints(slices)
-		return CreateMoveFunc(ce, timePart, slices), CreateCheckFunc(ce,timePart,slices)
+		return slices
 	},
-	_regexEnum: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
-		slices := []int{}
-		for _, s := range strings.Split(match, ",") {
-			num, _ := strconv.Atoi(s)
-			slices = append(slices, num)
+	_regexNumber: func(timePart string, match string) []int {
+		num, _ := strconv.Atoi(match)
+		return []int{num}
+	},
+}
+
+var _cronDayFunc = map[*regexp.Regexp]func(timePart string, match string) func(t time.Time) bool {
+	_regexLOnly: func(timePart string, match string) func(t time.Time) bool {
+		offset := lastDayOffset(match)
+		return func(t time.Time) bool {
+			return t.Day() == LastDay(t.Year(), t.Month()) - offset
 		}
-		sort.Ints(slices)
-		return CreateMoveFunc(ce, timePart, slices), CreateCheckFunc(ce,timePart,slices)
 	},
-	_regexLOnly: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
-		return func() time.Time {
-			if ce.CheckDay() {
-				return ce.ToTime()
-			}
-			nextDate := time.Time{}
-			nextMonth :=  time.Date(ce.Year,time.Month(ce.Month),1,0,0,0,0, time.Local).AddDate(0,1,0)
-			nextDate = nextMonth.AddDate(0,0,-1)
-			ce.SetValue(timePart, nextDate.Day())
-			return ce.ToTime()
-		}, func() bool {
-				return ce.ToTime().AddDate(0,0,1).Month() != time.Month(ce.Month)
+	_regexL: func(timePart string, match string) func(t time.Time) bool {
+		parts := strings.Split(match, "L")
+		if parts[0] == "" { //each weekend
+			return func(t time.Time) bool {
+				return t.Weekday() == time.Saturday
 			}
+		}
+		want, _ := strconv.Atoi(parts[0])
+		return func(t time.Time) bool {
+			return int(t.Weekday()) + 1 == want && t.AddDate(0,0,7).Month() != t.Month()
+		}
 	},
-	_regexL: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
-		return func() time.Time {
-			now := ce.ToTime()
-			nextDate := time.Time{}
-			parts := strings.Split(match, "L")
-			if parts[0] == "" { //each weekend
-				tmp := time.Date(now.Year(), now.Month(), now.Day(), 0 ,0,0,0, time.Local)
-				for  {
-					tmp := tmp.AddDate(0 , 0, 1)
-					if tmp.Month() != now.Month() {
-						return ce.ToTime()
+	_regexLW: func(timePart string, match string) func(t time.Time) bool {
+		offset := lastDayOffset(match)
+		if strings.HasPrefix(match, "LW") { //business days before the last business day
+			return func(t time.Time) bool {
+				day := NearestWeekday(t.Year(), t.Month(), LastDay(t.Year(), t.Month()), t.Location())
+				for i := 0; i < offset; {
+					day--
+					weekday := time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, t.Location()).Weekday()
+					if weekday != time.Saturday && weekday != time.Sunday {
+						i++
 					}
-					if tmp.Weekday() != time.Saturday {
-						continue
-					}
-					nextDate = tmp
-					break
-				}
-			} else {
-				want, _ := strconv.Atoi(parts[0])
-				nextDate = now
-				nextMonth :=  time.Date(ce.Year,time.Month(ce.Month),1,0,0,0,0, time.Local).AddDate(0,1,0)
-				for i := 1; i <= 7 ; i++ {
-					tmp := nextMonth.AddDate(0 , 0, -i)
-					if int(tmp.Weekday()) + 1 != want  {
-						continue
-					}
-					nextDate = tmp
-					break
-				}
-			}
-			ce.SetValue(timePart, nextDate.Day())
-			return ce.ToTime()
-		}, func() bool {
-				now := ce.ToTime()
-				parts := strings.Split(match, "L")
-				if parts[0] == "" {
-					return now.Weekday() == time.Saturday
-				}else{
-					want, _ := strconv.Atoi(parts[0])
-					return int(now.Weekday()) + 1 == want && now.AddDate(0,0,7).Month() != time.Month(ce.Month)
 				}
+				return t.Day() == day
 			}
+		}
+		return func(t time.Time) bool {
+			day := LastDay(t.Year(), t.Month()) - offset
+			return day >= 1 && t.Day() == NearestWeekday(t.Year(), t.Month(), day, t.Location())
+		}
 	},
-	_regexLW: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
-		return func() time.Time {
-			nextDate := time.Time{}
-			nextMonth :=  time.Date(ce.Year,time.Month(ce.Month),1,0,0,0,0, time.Local).AddDate(0,1,0)
-			for i := 1; i <= 7 ; i++ {
-				tmp := nextMonth.AddDate(0 , 0, -i)
-				if tmp.Weekday() == time.Saturday || tmp.Weekday() == time.Sunday {
-					continue
-				}
-				nextDate = tmp
-				break
-			}
-			ce.SetValue(timePart, nextDate.Day())
-			return ce.ToTime()
-		}, func() bool {
-				nextMonth :=  time.Date(ce.Year,time.Month(ce.Month),1,0,0,0,0, time.Local).AddDate(0,1,0)
-				tmp := time.Time{}
-				for i := 1; i <= 7 ; i++ {
-					tmp = nextMonth.AddDate(0 , 0, -i)
-					if tmp.Weekday() == time.Saturday || tmp.Weekday() == time.Sunday {
-						continue
-					}
-					break;
-				}
-				return ce.Day == tmp.Day()
-			}
+	_regexW: func(timePart string, match string) func(t time.Time) bool {
+		want, _ := strconv.Atoi(strings.TrimSuffix(match, "W"))
+		return func(t time.Time) bool {
+			return t.Day() == NearestWeekday(t.Year(), t.Month(), want, t.Location())
+		}
 	},
-	_regexWeekDay: func(ce *CronExpression, timePart string, match string) (func() time.Time, func() bool) {
+	_regexWeekDay: func(timePart string, match string) func(t time.Time) bool {
 		parts := strings.Split(match, "#")
 		day, _ := strconv.Atoi(parts[0])
 		num, _ := strconv.Atoi(parts[1])
-		return func() time.Time {
-			now := ce.ToTime()
-			start := time.Date(now.Year(),now.Month(),1,0,0,0,0, time.Local)
-			count := 0
-			for i:=0; i<31; i++{
-				if int(start.Weekday()) + 1 == day {
-					count++
-					if count == num {
-						break
-					}
-				}
-				start = start.AddDate(0,0,1)
-			}
-			if start.Month() != now.Month() {
-				return ce.ToTime()
-			} else {
-				ce.SetValue(timePart, start.Day())
-				return ce.ToTime()
-			}
-		}, func() bool {
-				now := ce.ToTime()
-				start := time.Date(now.Year(),now.Month(),1,0,0,0,0, time.Local)
-				count := 0
-				for i:=0; i<31; i++{
-					if int(start.Weekday()) + 1 == day {
-						count++
-						if count == num {
-							break
-						}
-					}
-					start = start.AddDate(0,0,1)
-				}
-				return start.Month() == now.Month() && start.Day() == now.Day()
-			}
+		return func(t time.Time) bool {
+			return int(t.Weekday()) + 1 == day && (t.Day() - 1) / 7 + 1 == num
+		}
 	},
 }
 
-var _valMap = map[string]func(ce *CronExpression, vals []int) []int {
-	"dayofweek": func(ce *CronExpression, vals []int) []int {
-		d := make(map[int]bool)
-		for _, val := range vals {
-			d[val] = true
-		}
-		days := []int{}
-		start := time.Date(ce.Year,time.Month(ce.Month),ce.Day,0,0,0,0, time.Local)
-		for start.Month() == time.Month(ce.Month) {
-			if d[int(start.Weekday())+1] {
-				days = append(days, start.Day())
-			}
-			start = start.AddDate(0,0,1)
-		}
-		return days
-	},
-	"dayofmonth": func(ce *CronExpression, vals []int) []int {
-		days := []int{}
-		for _, val := range vals {
-			if time.Date(ce.Year,time.Month(ce.Month),val,0,0,0,0,time.Local).Month() == time.Month(ce.Month) {
-				days = append(days, val)
-			} else {
-				break
-			}
-		}
-		return days
-	},
-	"year": func(ce *CronExpression, vals []int) []int {
-		checkYear := func(year int) bool {
-			if ce.Month == 2 && ce.Day == 29 {
-				return year % 4 == 0 && year % 100 != 0
-			} else {
-				return true
-			}
-		}
-		years := []int{}
-		for _, val := range vals {
-			if checkYear(val) {
-				years = append(years, val)
-			}
-		}
-		return years
-	},
+// validStep reports whether a /step is allowed in the field. Steps share the range of the field's values,
+// except for years where any step up to the width of the year window is allowed, e.g. 2020/5.
+func validStep(timePart string, step int) bool {
+	min := _timeRange[timePart][0]
+	max := _timeRange[timePart][1]
+	if timePart == "year" {
+		return 1 <= step && step <= max - min
+	}
+	return min <= step && step <= max
 }
 
-func NextValue(ce *CronExpression, timePart string, val int, nums []int) int {
-	if len(nums) == 0 {
+// lastDayOffset returns the N of L-N, L-NW and LW-N, 0 for L and LW.
+func lastDayOffset(match string) int {
+	parts := strings.Split(strings.TrimSuffix(match, "W"), "-")
+	if len(parts) < 2 {
+		return 0
+	}
+	offset, _ := strconv.Atoi(parts[1])
+	return offset
+}
+
+// maxLastDayOffset returns the largest N of L-N, L-NW or LW-N that matches a day in some month: the 1st of
+// 31 day months is L-30, and the longest months have 23 weekdays so the first one is LW-22.
+func maxLastDayOffset(match string) int {
+	if strings.HasPrefix(match, "LW-") {
+		return 22
+	}
+	return 30
+}
+
+// LastDay returns the number of days in the month, taking leap years into account.
+func LastDay(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// NearestWeekday returns the Monday to Friday day of month closest to day without leaving the month,
+// or -1 when the month has no such day.
+func NearestWeekday(year int, month time.Month, day int, loc *time.Location) int {
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if t.Month() != month {
 		return -1
 	}
-	for i := 1; i < len(nums); i++ {
-		if val >= nums[i-1] && val < nums[i] {
-			return nums[i]
+	switch t.Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return 3
 		}
+		return day - 1
+	case time.Sunday:
+		if t.AddDate(0, 0, 1).Month() != month {
+			return day - 2
+		}
+		return day + 1
 	}
-	return nums[0]
+	return day
 }
 
-func CreateMoveFunc(ce *CronExpression, timePart string, slices []int) func() time.Time {
-	return func() time.Time {
-		val := ce.GetValue(timePart)
-		vals := slices
-		if f, exists := _valMap[timePart]; exists {
-			vals = f(ce, vals)
+func init() {
+	_cronValueCheck[_regexEnum] = func(timePart string, match string) bool {
+		for _, item := range strings.Split(match, ",") {
+			if !_cronValueCheck[enumItem(item)](timePart, item) {
+				return false
+			}
 		}
-		nextVal := NextValue(ce , timePart, val, vals)
-		if nextVal == -1 {
-			return ce.ToTime()
+		return true
+	}
+	_cronValues[_regexEnum] = func(timePart string, match string) []int {
+		set := make(map[int]bool)
+		for _, item := range strings.Split(match, ",") {
+			for _, num := range _cronValues[enumItem(item)](timePart, item) {
+				set[num] = true
+			}
 		}
-		ce.SetValue(timePart, nextVal)
-		return ce.ToTime()
+		slices := []int{}
+		for num := range set {
+			slices = append(slices, num)
+		}
+		sort.Ints(slices)
+		return slices
 	}
 }
 
-func CreateCheckFunc(ce *CronExpression, timePart string, slices []int) func() bool {
-	return func() bool {
-		vals := slices
-		if f, exists := _valMap[timePart]; exists {
-			vals = f(ce, vals)
+// enumItem returns the pattern of a single item of a comma-separated list.
+func enumItem(item string) *regexp.Regexp {
+	for _, r := range _enumItems {
+		if r.MatchString(item) {
+			return r
 		}
-		val := ce.GetValue(timePart)
-		for _, num := range vals {
-			if num == val {
-				return true
-			}
+	}
+	return nil
+}
+
+func contains(nums []int, val int) bool {
+	for _, num := range nums {
+		if num == val {
+			return true
 		}
-		return false
 	}
+	return false
 }
 
 func Slice(start, end , min , max, slice int) []int {
@@ -1,52 +1,323 @@
 package cron
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
-	"os"
-	"os/exec"
+	"math/rand"
 	"regexp"
-	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type CronJob struct {
+	ID string //id= attribute, the key H tokens of the expression are hashed from
+	Jitter time.Duration //jitter= attribute, each run is delayed by a random duration up to Jitter
+	Calendars []Calendar //calendar= attributes, fire times excluded by any of them are skipped
+	Timeout time.Duration //timeout= attribute, a run still going after Timeout is killed, 0 means no timeout
+	OutputLimit int //output= attribute, bytes of the end of stdout and of stderr kept in each JobResult
+	LogFile string //log= attribute, file the output of every run is appended to
+	Dir string //cwd= attribute, working directory of the command, "" means the daemon's
+	Shell string //shell= attribute, or else the SHELL line of the cron file, "" means bash, or cmd on Windows
+	Env []string //NAME=value lines of the cron file before the job followed by its env= attributes
 	LastRunTime time.Time
-	*CronExpression
+	Expression Expression
+	*CronExpression //the expression when it is a cron expression, nil for @every and RRULE schedules, so only the job's own methods are safe to call on every job
 	*Job
+	Reboot bool
+	IsEnd bool
+	nextRunTime time.Time
 }
 
 func (cj *CronJob) NextRunTime() time.Time{
-	return cj.CronExpression.ToTime()
+	return cj.nextRunTime
 }
 
+// ToTime returns NextRunTime, it shadows ToTime of the embedded CronExpression so that @every and RRULE jobs
+// can call it too.
+func (cj *CronJob) ToTime() time.Time {
+	return cj.nextRunTime
+}
+
+// SetTime makes t the job's current fire time, MoveNext then advances from it, and returns the embedded
+// CronExpression, nil for @every and RRULE jobs.
+func (cj *CronJob) SetTime(t time.Time) *CronExpression {
+	cj.nextRunTime = t
+	if cj.CronExpression != nil {
+		cj.CronExpression.SetTime(t)
+	}
+	return cj.CronExpression
+}
+
+// Next returns the first fire time of the job's expression strictly after t, ignoring its calendars.
+func (cj *CronJob) Next(t time.Time) (time.Time, bool) {
+	return cj.Expression.Next(t)
+}
+
+// Prev returns the last fire time of the job's expression strictly before t, ignoring its calendars.
+func (cj *CronJob) Prev(t time.Time) (time.Time, bool) {
+	return cj.Expression.Prev(t)
+}
+
+// MoveNext is MoveNextAt(time.Now()).
+func (cj *CronJob) MoveNext() time.Time {
+	return cj.MoveNextAt(time.Now())
+}
+
+// MoveNextAt advances the job to the next fire time of its expression not excluded by its Calendars after both its
+// current fire time and now, so a job that is overdue after a late tick or a suspend fires once rather than once per
+// missed fire time. IsEnd is set once the expression never fires again.
+func (cj *CronJob) MoveNextAt(now time.Time) time.Time {
+	if cj.IsEnd {
+		return cj.nextRunTime
+	}
+	from := cj.nextRunTime
+	if now.After(from) {
+		from = now
+	}
+	next, ok := cj.NextAfter(from)
+	if !ok {
+		cj.IsEnd = true
+		return cj.nextRunTime
+	}
+	cj.nextRunTime = next
+	if cj.CronExpression != nil {
+		//keep ToTime() of the embedded expression on the job's next fire time like before Next existed
+		cj.CronExpression.SetTime(next)
+	}
+	return next
+}
+
+// NextAfter returns the first fire time strictly after t that no calendar of the job excludes,
+// false when there is none.
+func (cj *CronJob) NextAfter(t time.Time) (time.Time, bool) {
+	next, ok := cj.Next(t)
+	for ok {
+		excluded, until := cj.excluded(next)
+		if !excluded {
+			break
+		}
+		//first fire time at or after the end of the exclusion
+		next, ok = cj.Next(until.Add(-time.Nanosecond))
+	}
+	return next, ok
+}
+
+func (cj *CronJob) excluded(t time.Time) (bool, time.Time) {
+	for _, calendar := range cj.Calendars {
+		if excluded, until := calendar.Excluded(t); excluded {
+			return true, until
+		}
+	}
+	return false, time.Time{}
+}
+
+// JitterDelay returns a random delay between 0 and Jitter for the next run.
+func (cj *CronJob) JitterDelay() time.Duration {
+	if cj.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(cj.Jitter) + 1))
+}
+
+// ParseCronFile is like TryParseCronFile but panics when the file cannot be read or parsed.
 func ParseCronFile(filepath string) []*CronJob {
+	cronJobs, err := TryParseCronFile(filepath)
+	if err != nil {
+		panic(err)
+	}
+	return cronJobs
+}
+
+// TryParseCronFile reads and parses a cron file, one cron job per line.
+func TryParseCronFile(filepath string) ([]*CronJob, error) {
+	return (&Parser{}).ParseCronFile(filepath)
+}
+
+// ParseCronFile reads and parses a cron file, one cron job per line.
+func (p *Parser) ParseCronFile(filepath string) ([]*CronJob, error) {
 	content, err := ioutil.ReadFile(filepath)
 	if err != nil {
-		fmt.Printf("read cron file error: %v\n", err)
-		os.Exit(-1)
+		return nil, fmt.Errorf("read cron file error: %v", err)
 	}
-	return ParseCronData(string(content))
+	return p.ParseCronData(string(content))
 }
 
+// ParseCronData is like TryParseCronData but panics when a line is invalid.
 func ParseCronData(content string) []*CronJob {
+	cronJobs, err := TryParseCronData(content)
+	if err != nil {
+		panic(err)
+	}
+	return cronJobs
+}
+
+// TryParseCronData parses cron jobs separated by newlines, see Parser.ParseCronData.
+func TryParseCronData(content string) ([]*CronJob, error) {
+	return (&Parser{}).ParseCronData(content)
+}
+
+var _regexSkipLine = regexp.MustCompile(`^\s*(#.*)?$`) //blank lines and # comments
+var _regexVariable = regexp.MustCompile(`^\s*(?P<name>[A-Za-z_][A-Za-z0-9_]*)\s*=\s*(?P<value>.*?)\s*$`) //eg: CRON_MODE=unix
+
+// ParseCronData parses cron jobs separated by newlines, skipping blank lines and # comments.
+// A CRON_MODE=quartz|unix line switches the Mode and a CRON_TZ=<zone> line the Location of the lines after it.
+// Any other NAME=value line, such as SHELL, PATH or MAILTO, is added to the environment of the jobs after it.
+// The returned *ParseError carries the failing line number.
+func (p *Parser) ParseCronData(content string) ([]*CronJob, error) {
+	fp := *p
 	regexExpression := regexp.MustCompile("\r?\n")
 	expressions := regexExpression.Split(content,-1)
 	cronJobs := []*CronJob{}
-	for _, expression := range expressions {
-		cj := ParseCronJob(expression)
-		cronJobs = append(cronJobs, cj)
+	for i, expression := range expressions {
+		if _regexSkipLine.MatchString(expression) {
+			continue
+		}
+		var cj *CronJob
+		var err error
+		if match := _regexVariable.FindStringSubmatch(expression); match != nil && !_regexZone.MatchString(expression) {
+			err = fp.setVariable(match[1], match[2])
+		} else {
+			cj, err = fp.ParseCronJob(expression)
+		}
+		if err != nil {
+			if pe, ok := err.(*ParseError); ok {
+				pe.LineNo = i + 1
+			}
+			return nil, err
+		}
+		if cj != nil {
+			cronJobs = append(cronJobs, cj)
+		}
 	}
-	return cronJobs
+	return cronJobs, nil
 }
 
+// ParseCronJob is like TryParseCronJob but panics when the line is invalid.
 func ParseCronJob(line string) *CronJob {
-	cj := &CronJob{}
-	regexLine := regexp.MustCompile(`^(?P<cron>((\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)(\s+[0-9\-\*,]+)?))\s+(?P<job>(.+))$`)
+	cj, err := TryParseCronJob(line)
+	if err != nil {
+		panic(err)
+	}
+	return cj
+}
+
+// TryParseCronJob parses a cron file line made of a cron expression followed by a shell command.
+func TryParseCronJob(line string) (*CronJob, error) {
+	return (&Parser{}).ParseCronJob(line)
+}
+
+var _regexAttribute = regexp.MustCompile(`^(?P<name>[a-z]+)=(?P<value>\S*)\s+(?P<rest>\S.*)$`) //eg: id=backup ./backup.sh
+
+// _jobAttributes are the name=value settings a cron job line may have between its expression and its command.
+var _jobAttributes = map[string]func(cj *CronJob, value string) error {
+	"id": func(cj *CronJob, value string) error {
+		if value == "" {
+			return fmt.Errorf("id must not be empty")
+		}
+		cj.ID = value
+		return nil
+	},
+	"jitter": func(cj *CronJob, value string) error {
+		jitter, err := time.ParseDuration(value)
+		if err != nil || jitter < 0 {
+			return fmt.Errorf("jitter must be a positive duration such as 30s")
+		}
+		cj.Jitter = jitter
+		return nil
+	},
+	"timeout": func(cj *CronJob, value string) error {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("timeout must be a positive duration such as 10m")
+		}
+		cj.Timeout = timeout
+		return nil
+	},
+	"concurrency": func(cj *CronJob, value string) error {
+		concurrency, err := ParseConcurrency(value)
+		if err != nil {
+			return fmt.Errorf("concurrency must be allow, forbid or replace")
+		}
+		cj.Concurrency = concurrency
+		return nil
+	},
+	"retries": func(cj *CronJob, value string) error {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("retries must be a number such as 3")
+		}
+		cj.Retries = retries
+		return nil
+	},
+	"backoff": func(cj *CronJob, value string) error {
+		backoff, err := time.ParseDuration(value)
+		if err != nil || backoff <= 0 {
+			return fmt.Errorf("backoff must be a positive duration such as 30s")
+		}
+		cj.Backoff = backoff
+		return nil
+	},
+	"output": func(cj *CronJob, value string) error {
+		size, err := parseSize(value)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("output must be a positive size such as 4096, 16KB or 1MB")
+		}
+		cj.OutputLimit = size
+		return nil
+	},
+	"log": func(cj *CronJob, value string) error {
+		if value == "" {
+			return fmt.Errorf("log must be a file path")
+		}
+		cj.LogFile = value
+		return nil
+	},
+	"cwd": func(cj *CronJob, value string) error {
+		if value == "" {
+			return fmt.Errorf("cwd must be a directory")
+		}
+		cj.Dir = value
+		return nil
+	},
+	"shell": func(cj *CronJob, value string) error {
+		if value == "" {
+			return fmt.Errorf("shell must be a program such as /bin/sh")
+		}
+		cj.Shell = value
+		return nil
+	},
+	"env": func(cj *CronJob, value string) error {
+		match := _regexVariable.FindStringSubmatch(value)
+		if match == nil {
+			return fmt.Errorf("env must be a variable such as LANG=C")
+		}
+		cj.Env = setEnv(cj.Env, match[1], match[2])
+		return nil
+	},
+	"calendar": func(cj *CronJob, value string) error {
+		calendar, err := TryParseCalendarFile(value)
+		if err != nil {
+			return err
+		}
+		cj.Calendars = append(cj.Calendars, calendar)
+		return nil
+	},
+}
+
+// ParseCronJob parses a cron file line made of an expression in the parser's Mode, optional name=value job
+// attributes such as id=backup and a shell command. H tokens in the expression are hashed from the id, or from the
+// command when there is none.
+func (p *Parser) ParseCronJob(line string) (*CronJob, error) {
+	cj := &CronJob{Job: &Job{}, Env: p.Env}
+	regexLine := regexp.MustCompile(`^(?P<cron>(((CRON_TZ|TZ)=\S+\s+)?((DTSTART\S*\s+)?RRULE:\S+|@every\s+\S+(\s+from\s+\d{4}-\d{2}-\d{2}T\S+)?|@\w+|(\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)(\s+[0-9H()\-\*,/]+)?)))\s+(?P<job>(.+))$`)
+	if p.Mode == UnixMode {
+		regexLine = regexp.MustCompile(`^(?P<cron>(((CRON_TZ|TZ)=\S+\s+)?((DTSTART\S*\s+)?RRULE:\S+|@every\s+\S+(\s+from\s+\d{4}-\d{2}-\d{2}T\S+)?|@\w+|(\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+))))\s+(?P<job>(.+))$`)
+	}
 	match := regexLine.FindStringSubmatch(line)
 	if match == nil {
-		panic(line)
+		return nil, &ParseError{Line: line, Reason: "line must be a cron expression followed by a command"}
 	}
 	result := make(map[string]string)
 	groupNames := regexLine.SubexpNames()
@@ -55,33 +326,69 @@ func ParseCronJob(line string) *CronJob {
 			result[name] = match[i]
 		}
 	}
-	cj.CronExpression = ParseCronExpression(result["cron"])
-	cj.Job = &Job{}
-	cj.Desc = result["job"]
-	cj.Action = MakeAction(result["job"])
-	return cj
+	job := result["job"]
+	for {
+		attribute := _regexAttribute.FindStringSubmatch(job)
+		if attribute == nil {
+			break
+		}
+		set, exists := _jobAttributes[attribute[1]]
+		if !exists {
+			break
+		}
+		if err := set(cj, attribute[2]); err != nil {
+			return nil, &ParseError{Line: line, Field: attribute[1], Token: attribute[2], Reason: err.Error()}
+		}
+		job = attribute[3]
+	}
+	kp := *p
+	kp.Key = cj.ID
+	if kp.Key == "" {
+		kp.Key = job
+	}
+	expression, err := kp.ParseExpression(result["cron"])
+	if err != nil {
+		return nil, err
+	}
+	cj.Expression = expression
+	if ce, ok := expression.(*CronExpression); ok {
+		cj.CronExpression = ce
+		cj.Reboot = ce.Reboot
+	}
+	if cj.Shell == "" {
+		cj.Shell, _ = lookupEnv(cj.Env, "SHELL")
+	}
+	cj.Desc = job
+	action := &ShellAction{Script: job, Timeout: cj.Timeout, OutputLimit: cj.OutputLimit, LogFile: cj.LogFile,
+		Dir: cj.Dir, Shell: cj.Shell, Env: cj.Env}
+	cj.ContextAction = action.Run
+	cj.Action = func(c chan JobResult) {
+		action.Run(context.Background(), c)
+	}
+	return cj, nil
 }
 
+var _regexSize = regexp.MustCompile(`^(?i)([0-9]+)(B|K|KB|M|MB)?$`) //eg: 16KB
+
+// parseSize parses a number of bytes with an optional K, KB, M or MB suffix.
+func parseSize(value string) (int, error) {
+	match := _regexSize.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	size, err := strconv.Atoi(match[1])
+	switch strings.TrimSuffix(strings.ToUpper(match[2]), "B") {
+	case "K":
+		size *= 1024
+	case "M":
+		size *= 1024 * 1024
+	}
+	return size, err
+}
+
+// MakeAction returns the action running script with bash, or cmd on Windows, see ShellAction.
 func MakeAction(script string) func(c chan JobResult) {
 	return func(c chan JobResult) {
-		osterminal := ""
-		switch runtime.GOOS {
-		case "windows":
-			osterminal = "cmd"
-		default:
-			osterminal = "bash"
-		}
-		cmd := exec.Command(osterminal)
-		in := bytes.NewBuffer(nil)
-		cmd.Stdin = in
-		in.WriteString(script + "\n")
-		in.WriteString("exit\n")
-		err := cmd.Run()
-		if err != nil {
-			c <- JobResult{Code:-1000, Msg:fmt.Sprint(err)}
-		} else {
-			cmd.Wait()
-			c <- JobResult{cmd.ProcessState.ExitCode(), cmd.ProcessState.String()}
-		}
+		(&ShellAction{Script: script}).Run(context.Background(), c)
 	}
 }
@@ -0,0 +1,421 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	shapeAll = iota
+	shapeSingle
+	shapeRange
+	shapeStep
+	shapeList
+)
+
+// shape classifies the sorted values of a field so that descriptions read "every 20 minutes" rather than listing them.
+type shape struct {
+	kind int
+	values []int
+	from int
+	to int
+	step int
+}
+
+func shapeOf(values []int, min, max int) shape {
+	sh := shape{kind: shapeList, values: values, from: values[0], to: values[len(values)-1], step: 1}
+	if len(values) == max - min + 1 {
+		sh.kind = shapeAll
+		return sh
+	}
+	if len(values) == 1 {
+		sh.kind = shapeSingle
+		return sh
+	}
+	step := values[1] - values[0]
+	for i := 2; i < len(values); i++ {
+		if values[i] - values[i-1] != step {
+			return sh
+		}
+	}
+	if step == 1 {
+		sh.kind = shapeRange
+	} else if len(values) >= 3 {
+		sh.kind = shapeStep
+		sh.step = step
+	}
+	return sh
+}
+
+var _weekdayNames = map[string][]string {
+	"en": []string{"", "Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	"zh": []string{"", "周日", "周一", "周二", "周三", "周四", "周五", "周六"},
+}
+
+var _monthNames = map[string][]string {
+	"en": []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	"zh": []string{"", "1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+}
+
+var _ordinals = []string{"", "first", "second", "third", "fourth", "fifth"}
+
+// Describe renders the expression in English,
+// e.g. "every 20 minutes, between 08:00 and 18:59, Monday through Friday" for 0 */20 8-18 ? * 2-6 *.
+func (ce *CronExpression) Describe() string {
+	return ce.DescribeIn("en")
+}
+
+// DescribeIn renders the expression in English for "en" or in Chinese for "zh".
+func (ce *CronExpression) DescribeIn(lang string) string {
+	if lang == "zh" {
+		return ce.describeZh()
+	}
+	return ce.describeEn()
+}
+
+func joinEn(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+func numbers(values []int, format string) []string {
+	items := []string{}
+	for _, val := range values {
+		items = append(items, fmt.Sprintf(format, val))
+	}
+	return items
+}
+
+func names(values []int, table []string) []string {
+	items := []string{}
+	for _, val := range values {
+		items = append(items, table[val])
+	}
+	return items
+}
+
+func appendNotEmpty(parts []string, part string) []string {
+	if part == "" {
+		return parts
+	}
+	return append(parts, part)
+}
+
+func (ce *CronExpression) describeEn() string {
+	if ce.Reboot {
+		return "at startup"
+	}
+	parts := []string{}
+	second := shapeOf(ce.seconds.values(), 0, 59)
+	minute := shapeOf(ce.minutes.values(), 0, 59)
+	hour := shapeOf(ce.hours.values(), 0, 23)
+	if second.kind == shapeSingle && minute.kind == shapeSingle && hour.kind != shapeAll && hour.kind != shapeStep {
+		times := []string{}
+		for _, h := range ce.hours.values() {
+			if second.from == 0 {
+				times = append(times, fmt.Sprintf("%02d:%02d", h, minute.from))
+			} else {
+				times = append(times, fmt.Sprintf("%02d:%02d:%02d", h, minute.from, second.from))
+			}
+		}
+		parts = append(parts, "at " + joinEn(times))
+	} else {
+		secondText := ""
+		if second.kind != shapeSingle || second.from != 0 {
+			secondText = describeTimeEn(second, "second", 0, 59)
+		}
+		parts = appendNotEmpty(parts, secondText)
+		if minute.kind != shapeAll || secondText == "" {
+			parts = append(parts, describeTimeEn(minute, "minute", 0, 59))
+		}
+		switch hour.kind {
+		case shapeSingle, shapeRange:
+			parts = append(parts, fmt.Sprintf("between %02d:00 and %02d:59", hour.from, hour.to))
+		case shapeStep:
+			parts = append(parts, describeStepEn(hour, "hour", 0, 23))
+		case shapeList:
+			parts = append(parts, "during hours " + joinEn(numbers(ce.hours.values(), "%02d:00")))
+		}
+	}
+	days := []string{}
+	days = appendNotEmpty(days, ce.describeDayOfMonthEn())
+	days = appendNotEmpty(days, ce.describeDayOfWeekEn())
+	if ce.dayOr && len(days) == 2 {
+		days = []string{days[0] + " or " + days[1]}
+	}
+	parts = append(parts, days...)
+	switch month := shapeOf(ce.months.values(), 1, 12); month.kind {
+	case shapeRange:
+		parts = append(parts, fmt.Sprintf("%s through %s", _monthNames["en"][month.from], _monthNames["en"][month.to]))
+	case shapeStep:
+		parts = append(parts, describePeriodStepEn(month, "month", _monthNames["en"][month.from], _monthNames["en"][month.to], 1, 12))
+	case shapeSingle, shapeList:
+		parts = append(parts, "in " + joinEn(names(ce.months.values(), _monthNames["en"])))
+	}
+	switch year := shapeOf(ce.years.values(), _timeRange["year"][0], _timeRange["year"][1]); year.kind {
+	case shapeRange:
+		parts = append(parts, fmt.Sprintf("in %d through %d", year.from, year.to))
+	case shapeStep:
+		parts = append(parts, describePeriodStepEn(year, "year", strconv.Itoa(year.from), strconv.Itoa(year.to), _timeRange["year"][0], _timeRange["year"][1]))
+	case shapeSingle, shapeList:
+		parts = append(parts, "in " + joinEn(numbers(ce.years.values(), "%d")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeTimeEn(sh shape, unit string, min, max int) string {
+	switch sh.kind {
+	case shapeAll:
+		return "every " + unit
+	case shapeSingle:
+		return fmt.Sprintf("at %s %d", unit, sh.from)
+	case shapeRange:
+		return fmt.Sprintf("every %s from %s %d through %d", unit, unit, sh.from, sh.to)
+	case shapeStep:
+		return describeStepEn(sh, unit, min, max)
+	}
+	return fmt.Sprintf("at %ss %s", unit, joinEn(numbers(sh.values, "%d")))
+}
+
+func describeStepEn(sh shape, unit string, min, max int) string {
+	text := fmt.Sprintf("every %d %ss", sh.step, unit)
+	if sh.from != min || sh.to + sh.step <= max {
+		text += fmt.Sprintf(" from %s %d through %d", unit, sh.from, sh.to)
+	}
+	return text
+}
+
+// describePeriodStepEn renders a stepped month or year field, e.g. "every 5 years starting in 2020" for 2020/5.
+func describePeriodStepEn(sh shape, unit string, from, to string, min, max int) string {
+	text := fmt.Sprintf("every %d %ss", sh.step, unit)
+	if sh.to + sh.step <= max {
+		return text + " from " + from + " through " + to
+	}
+	if sh.from != min {
+		return text + " starting in " + from
+	}
+	return text
+}
+
+func (ce *CronExpression) describeDayOfMonthEn() string {
+	token := ce.dayOfMonthToken
+	offset := strconv.Itoa(lastDayOffset(token))
+	switch {
+	case token == "L":
+		return "on the last day of the month"
+	case token == "LW":
+		return "on the last weekday of the month"
+	case strings.HasPrefix(token, "LW-"):
+		return offset + " weekdays before the last weekday of the month"
+	case strings.HasPrefix(token, "L-") && strings.HasSuffix(token, "W"):
+		return "on the weekday nearest " + offset + " days before the last day of the month"
+	case strings.HasPrefix(token, "L-"):
+		return offset + " days before the last day of the month"
+	case strings.HasSuffix(token, "W"):
+		return "on the weekday nearest day " + strings.TrimSuffix(token, "W") + " of the month"
+	}
+	if ce.daysOfMonth == nil {
+		return ""
+	}
+	switch sh := shapeOf(ce.daysOfMonth.values(), 1, 31); sh.kind {
+	case shapeAll:
+		return ""
+	case shapeSingle:
+		return fmt.Sprintf("on day %d of the month", sh.from)
+	case shapeRange:
+		return fmt.Sprintf("on days %d through %d of the month", sh.from, sh.to)
+	case shapeStep:
+		return describeStepEn(sh, "day", 1, 31) + " of the month"
+	}
+	return "on days " + joinEn(numbers(ce.daysOfMonth.values(), "%d")) + " of the month"
+}
+
+func (ce *CronExpression) describeDayOfWeekEn() string {
+	token := ce.dayOfWeekToken
+	weekdays := _weekdayNames["en"]
+	switch {
+	case token == "L":
+		return "on " + weekdays[7]
+	case strings.HasSuffix(token, "L"):
+		day, _ := strconv.Atoi(strings.TrimSuffix(token, "L"))
+		return "on the last " + weekdays[day] + " of the month"
+	case strings.Contains(token, "#"):
+		parts := strings.Split(token, "#")
+		day, _ := strconv.Atoi(parts[0])
+		num, _ := strconv.Atoi(parts[1])
+		return "on the " + _ordinals[num] + " " + weekdays[day] + " of the month"
+	}
+	if ce.daysOfWeek == nil {
+		return ""
+	}
+	switch sh := shapeOf(ce.daysOfWeek.values(), 1, 7); sh.kind {
+	case shapeAll:
+		return ""
+	case shapeRange:
+		if sh.to - sh.from >= 2 {
+			return weekdays[sh.from] + " through " + weekdays[sh.to]
+		}
+	}
+	return "on " + joinEn(names(ce.daysOfWeek.values(), weekdays))
+}
+
+func (ce *CronExpression) describeZh() string {
+	if ce.Reboot {
+		return "程序启动时"
+	}
+	parts := []string{}
+	switch year := shapeOf(ce.years.values(), _timeRange["year"][0], _timeRange["year"][1]); year.kind {
+	case shapeRange:
+		parts = append(parts, fmt.Sprintf("%d年至%d年", year.from, year.to))
+	case shapeStep:
+		parts = append(parts, describePeriodStepZh(year, "年", strconv.Itoa(year.from) + "年", strconv.Itoa(year.to) + "年", _timeRange["year"][0], _timeRange["year"][1]))
+	case shapeSingle, shapeList:
+		parts = append(parts, strings.Join(numbers(ce.years.values(), "%d"), "、") + "年")
+	}
+	switch month := shapeOf(ce.months.values(), 1, 12); month.kind {
+	case shapeRange:
+		parts = append(parts, _monthNames["zh"][month.from] + "至" + _monthNames["zh"][month.to])
+	case shapeStep:
+		parts = append(parts, describePeriodStepZh(month, "个月", _monthNames["zh"][month.from], _monthNames["zh"][month.to], 1, 12))
+	case shapeSingle, shapeList:
+		parts = append(parts, strings.Join(names(ce.months.values(), _monthNames["zh"]), "、"))
+	}
+	days := []string{}
+	days = appendNotEmpty(days, ce.describeDayOfMonthZh())
+	days = appendNotEmpty(days, ce.describeDayOfWeekZh())
+	if ce.dayOr && len(days) == 2 {
+		days = []string{days[0] + "或" + days[1]}
+	}
+	if len(days) == 0 {
+		days = []string{"每天"}
+	}
+	parts = append(parts, days...)
+	second := shapeOf(ce.seconds.values(), 0, 59)
+	minute := shapeOf(ce.minutes.values(), 0, 59)
+	hour := shapeOf(ce.hours.values(), 0, 23)
+	if second.kind == shapeSingle && minute.kind == shapeSingle && hour.kind != shapeAll && hour.kind != shapeStep {
+		times := []string{}
+		for _, h := range ce.hours.values() {
+			if second.from == 0 {
+				times = append(times, fmt.Sprintf("%02d:%02d", h, minute.from))
+			} else {
+				times = append(times, fmt.Sprintf("%02d:%02d:%02d", h, minute.from, second.from))
+			}
+		}
+		parts = append(parts, strings.Join(times, "、"))
+		return strings.Join(parts, "，")
+	}
+	switch hour.kind {
+	case shapeSingle, shapeRange:
+		parts = append(parts, fmt.Sprintf("%02d:00至%02d:59之间", hour.from, hour.to))
+	case shapeStep:
+		parts = append(parts, describeStepZh(hour, "小时", "点", 0, 23))
+	case shapeList:
+		parts = append(parts, strings.Join(numbers(ce.hours.values(), "%d点"), "、"))
+	}
+	secondText := ""
+	if second.kind != shapeSingle || second.from != 0 {
+		secondText = describeTimeZh(second, "秒", "秒", 0, 59)
+	}
+	if minute.kind != shapeAll || secondText == "" {
+		parts = append(parts, describeTimeZh(minute, "分钟", "分", 0, 59))
+	}
+	parts = appendNotEmpty(parts, secondText)
+	return strings.Join(parts, "，")
+}
+
+func describeTimeZh(sh shape, unit string, point string, min, max int) string {
+	switch sh.kind {
+	case shapeAll:
+		return "每" + unit
+	case shapeSingle:
+		return fmt.Sprintf("第%d%s", sh.from, point)
+	case shapeRange:
+		return fmt.Sprintf("第%d%s至第%d%s每%s", sh.from, point, sh.to, point, unit)
+	case shapeStep:
+		return describeStepZh(sh, unit, point, min, max)
+	}
+	return "第" + strings.Join(numbers(sh.values, "%d"), "、") + point
+}
+
+func describeStepZh(sh shape, unit string, point string, min, max int) string {
+	text := fmt.Sprintf("每%d%s", sh.step, unit)
+	if sh.from != min || sh.to + sh.step <= max {
+		text = fmt.Sprintf("第%d%s至第%d%s", sh.from, point, sh.to, point) + text
+	}
+	return text
+}
+
+// describePeriodStepZh renders a stepped month or year field, e.g. "2020年起每5年" for 2020/5.
+func describePeriodStepZh(sh shape, unit string, from, to string, min, max int) string {
+	text := fmt.Sprintf("每%d%s", sh.step, unit)
+	if sh.to + sh.step <= max {
+		return from + "至" + to + text
+	}
+	if sh.from != min {
+		return from + "起" + text
+	}
+	return text
+}
+
+func (ce *CronExpression) describeDayOfMonthZh() string {
+	token := ce.dayOfMonthToken
+	offset := strconv.Itoa(lastDayOffset(token))
+	switch {
+	case token == "L":
+		return "每月最后一天"
+	case token == "LW":
+		return "每月最后一个工作日"
+	case strings.HasPrefix(token, "LW-"):
+		return "每月最后一个工作日前" + offset + "个工作日"
+	case strings.HasPrefix(token, "L-") && strings.HasSuffix(token, "W"):
+		return "每月最后一天前" + offset + "天最近的工作日"
+	case strings.HasPrefix(token, "L-"):
+		return "每月最后一天前" + offset + "天"
+	case strings.HasSuffix(token, "W"):
+		return "每月离" + strings.TrimSuffix(token, "W") + "号最近的工作日"
+	}
+	if ce.daysOfMonth == nil {
+		return ""
+	}
+	switch sh := shapeOf(ce.daysOfMonth.values(), 1, 31); sh.kind {
+	case shapeAll:
+		return ""
+	case shapeRange:
+		return fmt.Sprintf("每月%d号至%d号", sh.from, sh.to)
+	case shapeStep:
+		return "每月" + describeStepZh(sh, "天", "号", 1, 31)
+	}
+	return "每月" + strings.Join(numbers(ce.daysOfMonth.values(), "%d"), "、") + "号"
+}
+
+func (ce *CronExpression) describeDayOfWeekZh() string {
+	token := ce.dayOfWeekToken
+	weekdays := _weekdayNames["zh"]
+	switch {
+	case token == "L":
+		return "每" + weekdays[7]
+	case strings.HasSuffix(token, "L"):
+		day, _ := strconv.Atoi(strings.TrimSuffix(token, "L"))
+		return "每月最后一个" + weekdays[day]
+	case strings.Contains(token, "#"):
+		parts := strings.Split(token, "#")
+		day, _ := strconv.Atoi(parts[0])
+		return "每月第" + parts[1] + "个" + weekdays[day]
+	}
+	if ce.daysOfWeek == nil {
+		return ""
+	}
+	switch sh := shapeOf(ce.daysOfWeek.values(), 1, 7); sh.kind {
+	case shapeAll:
+		return ""
+	case shapeRange:
+		if sh.to - sh.from >= 2 {
+			return weekdays[sh.from] + "至" + weekdays[sh.to]
+		}
+	}
+	return "每" + strings.Join(names(ce.daysOfWeek.values(), weekdays), "、")
+}
@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"math"
+	"regexp"
+	"time"
+)
+
+// EveryExpression fires at Anchor and then every Interval, e.g. @every 1m30s.
+type EveryExpression struct {
+	Interval time.Duration
+	Anchor time.Time
+}
+
+// Every is like TryEvery but panics when interval is not positive.
+func Every(interval time.Duration, anchor time.Time) *EveryExpression {
+	e, err := TryEvery(interval, anchor)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// TryEvery returns an expression firing every interval after anchor, a *ParseError when interval is not positive.
+func TryEvery(interval time.Duration, anchor time.Time) (*EveryExpression, error) {
+	if interval <= 0 {
+		return nil, &ParseError{Line: "@every " + interval.String(), Field: "interval", Token: interval.String(), Reason: "must be positive"}
+	}
+	return &EveryExpression{Interval: interval, Anchor: anchor}, nil
+}
+
+var _regexEvery = regexp.MustCompile(`^@every\s+(?P<interval>\S+)(\s+from\s+(?P<anchor>\S+))?$`) //eg: @every 1m30s from 2020-03-06T08:00:00+08:00
+
+// TryParseEvery parses an @every schedule, see Parser.ParseEvery.
+func TryParseEvery(line string) (*EveryExpression, error) {
+	return (&Parser{}).ParseEvery(line)
+}
+
+// ParseEvery parses "@every <duration>" optionally followed by "from <anchor>", where anchor is RFC 3339 or a
+// 2006-01-02T15:04:05 time in the parser's Location. Without an anchor the schedule starts at the time it is parsed.
+func (p *Parser) ParseEvery(line string) (*EveryExpression, error) {
+	match := _regexEvery.FindStringSubmatch(line)
+	if match == nil {
+		return nil, &ParseError{Line: line, Reason: "expected @every <duration> [from <time>]"}
+	}
+	interval, err := time.ParseDuration(match[1])
+	if err != nil {
+		return nil, &ParseError{Line: line, Field: "interval", Token: match[1], Reason: "must be a duration such as 1m30s"}
+	}
+	if interval <= 0 {
+		return nil, &ParseError{Line: line, Field: "interval", Token: match[1], Reason: "must be positive"}
+	}
+	if interval % time.Second != 0 {
+		return nil, &ParseError{Line: line, Field: "interval", Token: match[1], Reason: "must be a whole number of seconds"}
+	}
+	anchor := time.Now().Truncate(time.Second)
+	if match[3] != "" {
+		anchor, err = time.Parse(time.RFC3339, match[3])
+		if err != nil {
+			anchor, err = time.ParseInLocation("2006-01-02T15:04:05", match[3], p.location())
+		}
+		if err != nil {
+			return nil, &ParseError{Line: line, Field: "anchor", Token: match[3], Reason: "must be an RFC 3339 time"}
+		}
+	}
+	return TryEvery(interval, anchor)
+}
+
+// Next returns false when Interval is not positive or t is too far from Anchor, about 292 years, for a time.Duration.
+func (e *EveryExpression) Next(t time.Time) (time.Time, bool) {
+	if e.Interval <= 0 {
+		return time.Time{}, false
+	}
+	if t.Before(e.Anchor) {
+		return e.Anchor, true
+	}
+	elapsed, ok := e.elapsed(t)
+	if !ok || elapsed > math.MaxInt64 - e.Interval {
+		return time.Time{}, false
+	}
+	n := elapsed / e.Interval + 1
+	return e.Anchor.Add(n * e.Interval), true
+}
+
+// Prev returns false when Interval is not positive or t is too far from Anchor, about 292 years, for a time.Duration.
+func (e *EveryExpression) Prev(t time.Time) (time.Time, bool) {
+	if e.Interval <= 0 || !t.After(e.Anchor) {
+		return time.Time{}, false
+	}
+	elapsed, ok := e.elapsed(t)
+	if !ok {
+		return time.Time{}, false
+	}
+	n := (elapsed - 1) / e.Interval
+	return e.Anchor.Add(n * e.Interval), true
+}
+
+// elapsed returns t - Anchor, false when time.Time.Sub saturated because it does not fit in a time.Duration.
+func (e *EveryExpression) elapsed(t time.Time) (time.Duration, bool) {
+	elapsed := t.Sub(e.Anchor)
+	return elapsed, e.Anchor.Add(elapsed).Equal(t)
+}
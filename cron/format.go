@@ -0,0 +1,112 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// String returns the canonical form of the expression, which parses back to an equivalent expression:
+// names become numbers, value sets are rendered in a single normalized way (0/1 becomes *, 1,2,3 becomes 1-3),
+// Quartz expressions always have 7 fields and UnixMode expressions keep their 5 fields.
+func (ce *CronExpression) String() string {
+	prefix := ""
+	if ce.Location != nil && ce.Location != time.Local {
+		prefix = "CRON_TZ=" + ce.Location.String() + " "
+	}
+	if ce.Reboot {
+		return prefix + "@reboot"
+	}
+	if ce.mode == UnixMode {
+		dayOfMonth := ce.dayOfMonthToken
+		if dayOfMonth == "" {
+			dayOfMonth = formatSet(ce.daysOfMonth.values(), 1, 31, !ce.dayOr)
+		}
+		dayOfWeek := ce.dayOfWeekToken
+		if dayOfWeek == "" && ce.daysOfWeek.count() == 7 && !ce.dayOr {
+			dayOfWeek = "*"
+		} else if dayOfWeek == "" {
+			dayOfWeek = formatSet(toUnixWeekdays(ce.daysOfWeek.values()), 0, 7, !ce.dayOr)
+		}
+		return prefix + strings.Join([]string{
+			formatSet(ce.minutes.values(), 0, 59, true),
+			formatSet(ce.hours.values(), 0, 23, true),
+			dayOfMonth,
+			formatSet(ce.months.values(), 1, 12, true),
+			dayOfWeek,
+		}, " ")
+	}
+	dayOfMonth := "?"
+	if ce.dayOfMonthToken != "" {
+		dayOfMonth = ce.dayOfMonthToken
+	} else if ce.daysOfMonth != nil {
+		dayOfMonth = formatSet(ce.daysOfMonth.values(), 1, 31, true)
+	}
+	dayOfWeek := "?"
+	if ce.dayOfWeekToken != "" {
+		dayOfWeek = ce.dayOfWeekToken
+	} else if ce.daysOfWeek != nil {
+		dayOfWeek = formatSet(ce.daysOfWeek.values(), 1, 7, true)
+	}
+	if dayOfMonth == "?" && dayOfWeek == "?" {
+		dayOfMonth = "*"
+	}
+	return prefix + strings.Join([]string{
+		formatSet(ce.seconds.values(), 0, 59, true),
+		formatSet(ce.minutes.values(), 0, 59, true),
+		formatSet(ce.hours.values(), 0, 23, true),
+		dayOfMonth,
+		formatSet(ce.months.values(), 1, 12, true),
+		dayOfWeek,
+		formatSet(ce.years.values(), _timeRange["year"][0], _timeRange["year"][1], true),
+	}, " ")
+}
+
+func toUnixWeekdays(days []int) []int {
+	weekdays := []int{}
+	for _, day := range days {
+		weekdays = append(weekdays, day - 1)
+	}
+	return weekdays
+}
+
+// formatSet renders sorted values as *, */step, start/step or a list of values, a-b ranges and a-b/step ranges.
+// star is false when * must be avoided because it changes the meaning of a Unix day field.
+func formatSet(values []int, min, max int, star bool) string {
+	if len(values) == max - min + 1 && star {
+		return "*"
+	}
+	if sh := shapeOf(values, min, max); sh.kind == shapeStep && sh.to + sh.step > max {
+		if sh.from == min && star {
+			return fmt.Sprintf("*/%d", sh.step)
+		}
+		return fmt.Sprintf("%d/%d", sh.from, sh.step)
+	}
+	items := []string{}
+	for i := 0; i < len(values); {
+		j := i + 1
+		if j < len(values) {
+			step := values[j] - values[i]
+			for j + 1 < len(values) && values[j+1] - values[j] == step {
+				j++
+			}
+			if j - i >= 2 {
+				if step == 1 {
+					items = append(items, fmt.Sprintf("%d-%d", values[i], values[j]))
+				} else {
+					items = append(items, fmt.Sprintf("%d-%d/%d", values[i], values[j], step))
+				}
+				i = j + 1
+				continue
+			}
+		}
+		items = append(items, fmt.Sprintf("%d", values[i]))
+		i++
+	}
+	return strings.Join(items, ",")
+}
+
+// String returns "@every <interval> from <anchor>", which parses back to the same schedule.
+func (e *EveryExpression) String() string {
+	return fmt.Sprintf("@every %s from %s", e.Interval, e.Anchor.Format(time.RFC3339))
+}
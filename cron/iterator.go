@@ -0,0 +1,75 @@
+package cron
+
+import "time"
+
+// MaxIterations caps the number of fire times an Iterator yields, so that Between and NextN stay bounded for
+// very frequent schedules such as * * * * * ? * over a long window.
+var MaxIterations = 100000
+
+// Iterator walks the fire times of an expression without modifying it.
+//
+//	it := ce.Iterate(from, time.Time{})
+//	for it.Next() {
+//		fmt.Println(it.Time())
+//	}
+type Iterator struct {
+	expression Expression
+	current time.Time
+	end time.Time //zero means no end
+	count int
+	done bool
+}
+
+// Iterate returns an iterator over the fire times of e strictly after from and strictly before end,
+// a zero end does not bound the iteration.
+func Iterate(e Expression, from, end time.Time) *Iterator {
+	return &Iterator{expression: e, current: from, end: end}
+}
+
+// Next advances to the next fire time and reports whether there is one. It returns false once the expression is
+// exhausted, the end is reached or MaxIterations fire times were yielded.
+func (it *Iterator) Next() bool {
+	if it.done || it.count >= MaxIterations {
+		it.done = true
+		return false
+	}
+	next, ok := it.expression.Next(it.current)
+	if !ok || (!it.end.IsZero() && !next.Before(it.end)) {
+		it.done = true
+		return false
+	}
+	it.current = next
+	it.count++
+	return true
+}
+
+// Time returns the fire time the last call to Next advanced to.
+func (it *Iterator) Time() time.Time {
+	return it.current
+}
+
+// Iterate returns an iterator over the fire times strictly after from and strictly before end, see Iterate.
+// The iterator yields nothing when the expression has already reached its end.
+func (ce *CronExpression) Iterate(from, end time.Time) *Iterator {
+	it := Iterate(ce, from, end)
+	it.done = ce.IsEnd
+	return it
+}
+
+// NextN returns up to n fire times strictly after from, fewer when the expression ends.
+func (ce *CronExpression) NextN(from time.Time, n int) []time.Time {
+	times := []time.Time{}
+	for it := ce.Iterate(from, time.Time{}); len(times) < n && it.Next(); {
+		times = append(times, it.Time())
+	}
+	return times
+}
+
+// Between returns the fire times t with start <= t < end, at most MaxIterations of them.
+func (ce *CronExpression) Between(start, end time.Time) []time.Time {
+	times := []time.Time{}
+	for it := ce.Iterate(start.Add(-time.Nanosecond), end); it.Next(); {
+		times = append(times, it.Time())
+	}
+	return times
+}
@@ -2,6 +2,11 @@ package cron
 
 import (
 	"../util"
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
 )
 
 const  (
@@ -10,25 +15,167 @@ const  (
 	Error = -1
 )
 
+const (
+	ErrorCode = -1000 //JobResult.Code when the command could not be run
+	TimeoutCode = -1001 //JobResult.Code when the command was killed after its timeout
+	CanceledCode = -1002 //JobResult.Code when the command was killed because its run was canceled
+)
+
+// Concurrency decides what Run does while a previous run of the job is still going.
+type Concurrency int
+
+const (
+	// Allow starts the new run next to the running ones.
+	Allow Concurrency = iota
+	// Forbid skips the new run.
+	Forbid
+	// Replace cancels the running ones and starts the new run once they have stopped.
+	Replace
+)
+
+// ParseConcurrency returns the Concurrency named allow, forbid or replace.
+func ParseConcurrency(name string) (Concurrency, error) {
+	switch strings.ToLower(name) {
+	case "allow":
+		return Allow, nil
+	case "forbid":
+		return Forbid, nil
+	case "replace":
+		return Replace, nil
+	}
+	return Allow, &ParseError{Line: name, Field: "concurrency", Token: name, Reason: "must be allow, forbid or replace"}
+}
+
 type Job struct {
 	Pid int
-	Action func(c chan JobResult)
+	Action func(c chan JobResult) //runs the job when ContextAction is nil
+	ContextAction func(ctx context.Context, c chan JobResult) //preferred over Action, ctx is done when the run is canceled
 	Desc string
 	Status int
 	LastRunResult int
+	Concurrency Concurrency //concurrency= attribute
+	Retries int //retries= attribute, how many times a failed run is attempted again
+	Backoff time.Duration //backoff= attribute, delay before the first retry, doubled for each further retry
+	OnResult func(result JobResult) //called with the result of every attempt
+	mutex sync.Mutex
+	runs map[*jobRun]bool //the runs still going
+	skipped int
+	replaced int
 }
 
 type JobResult struct {
 	Code int
 	Msg string
+	Attempt int //1 for the first attempt of a run, 2 for its first retry and so on
+	Stdout string //end of the standard output of the command
+	Stderr string //end of the standard error of the command
+	StdoutTruncated bool //the beginning of the standard output was dropped
+	StderrTruncated bool //the beginning of the standard error was dropped
+}
+
+// DefaultBackoff is the delay before the first retry of jobs with retries but no backoff.
+var DefaultBackoff = 10 * time.Second
+
+type jobRun struct {
+	cancel context.CancelFunc
+	done chan struct{}
 }
 
 func(job *Job) Run() {
+	job.mutex.Lock()
+	if len(job.runs) > 0 && job.Concurrency == Forbid {
+		job.skipped++
+		job.mutex.Unlock()
+		util.Log("Skip Job: %s. Previous run is still running, skipped %d times.", job.Desc, job.skipped)
+		return
+	}
+	if len(job.runs) > 0 && job.Concurrency == Replace {
+		job.replaced++
+		util.Log("Replace Job: %s. Canceling %d running, replaced %d times.", job.Desc, len(job.runs), job.replaced)
+		previous := []*jobRun{}
+		for run := range job.runs {
+			run.cancel()
+			previous = append(previous, run)
+		}
+		job.mutex.Unlock()
+		for _, run := range previous {
+			<- run.done
+		}
+		job.mutex.Lock()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &jobRun{cancel: cancel, done: make(chan struct{})}
+	if job.runs == nil {
+		job.runs = map[*jobRun]bool{}
+	}
+	job.runs[run] = true
 	job.Status = Running
+	job.mutex.Unlock()
 	util.Log("Start Job: %s", job.Desc)
+	result := job.attempt(ctx, 1)
+	for attempt := 2; result.Code != 0 && attempt <= job.Retries + 1; attempt++ {
+		delay := job.retryDelay(attempt - 1)
+		util.Log("Retry Job: %s. Code: %d, attempt %d in %s.", job.Desc, result.Code, attempt, delay)
+		select {
+		case <- time.After(delay):
+			result = job.attempt(ctx, attempt)
+			continue
+		case <- ctx.Done():
+		}
+		break
+	}
+	cancel()
+	job.mutex.Lock()
+	delete(job.runs, run)
+	if len(job.runs) == 0 {
+		job.Status = Wait
+	}
+	job.LastRunResult = result.Code
+	job.mutex.Unlock()
+	close(run.done)
+	util.Log("Finish Job: %s. Code: %d, Msg: %s.", job.Desc, result.Code, result.Msg)
+	if result.Code != 0 && result.Stderr != "" {
+		util.Log("Stderr of Job: %s.\n%s", job.Desc, result.Stderr)
+	}
+}
+
+func (job *Job) attempt(ctx context.Context, attempt int) JobResult {
 	c := make(chan JobResult)
-	go job.Action(c)
+	if job.ContextAction != nil {
+		go job.ContextAction(ctx, c)
+	} else {
+		go job.Action(c)
+	}
 	result := <- c
-	job.Status = Wait
-	util.Log("Finish Job: %s. Code: %d, Msg: %s.", job.Desc, result.Code, result.Msg)
-}
\ No newline at end of file
+	result.Attempt = attempt
+	if job.OnResult != nil {
+		job.OnResult(result)
+	}
+	return result
+}
+
+// retryDelay returns Backoff doubled for each retry before the n-th, plus a random jitter of up to half of it.
+func (job *Job) retryDelay(n int) time.Duration {
+	delay := job.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	for i := 1; i < n && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay) / 2 + 1))
+}
+
+// Skipped returns how many runs the Forbid policy skipped.
+func (job *Job) Skipped() int {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	return job.skipped
+}
+
+// Replaced returns how many times the Replace policy canceled running runs.
+func (job *Job) Replaced() int {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	return job.replaced
+}
@@ -0,0 +1,137 @@
+package cron
+
+import (
+	"time"
+)
+
+// The stateful API below predates Next and Prev. It walks Year, Month, Day, Hour, Minute and Second field by field
+// and is kept for callers written against it.
+
+var _legacyParts = []string{"year", "month", "day", "hour", "minute", "second"}
+
+var _legacyMin = map[string]int {
+	"year": 1970,
+	"month": 1,
+	"day": 1,
+	"hour": 0,
+	"minute": 0,
+	"second": 0,
+}
+
+// setLegacyFuncs fills the MoveNext and Check fields from the compiled fields of the expression.
+func (ce *CronExpression) setLegacyFuncs() {
+	ce.MoveNextSecond, ce.CheckSecond = CreateMoveFunc(ce, "second", ce.seconds.values()), CreateCheckFunc(ce, "second", ce.seconds.values())
+	ce.MoveNextMinute, ce.CheckMinute = CreateMoveFunc(ce, "minute", ce.minutes.values()), CreateCheckFunc(ce, "minute", ce.minutes.values())
+	ce.MoveNextHour, ce.CheckHour = CreateMoveFunc(ce, "hour", ce.hours.values()), CreateCheckFunc(ce, "hour", ce.hours.values())
+	ce.MoveNextMonth, ce.CheckMonth = CreateMoveFunc(ce, "month", ce.months.values()), CreateCheckFunc(ce, "month", ce.months.values())
+	ce.MoveNextYear, ce.CheckYear = CreateMoveFunc(ce, "year", ce.years.values()), CreateCheckFunc(ce, "year", ce.years.values())
+	ce.MoveNextDay = func() time.Time {
+		day := NextValue(ce, "day", ce.Day, ce.matchingDays())
+		if day == -1 {
+			return ce.ToTime()
+		}
+		ce.SetValue("day", day)
+		return ce.ToTime()
+	}
+	ce.CheckDay = func() bool {
+		return contains(ce.matchingDays(), ce.Day)
+	}
+}
+
+// matchingDays returns the days of the current month matching the day fields.
+func (ce *CronExpression) matchingDays() []int {
+	days := []int{}
+	last := LastDay(ce.Year, time.Month(ce.Month))
+	for day := 1; day <= last; day++ {
+		if ce.checkDay(time.Date(ce.Year, time.Month(ce.Month), day, 0, 0, 0, 0, ce.location())) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// SetValue sets a field of the current time and moves the fields below it to their first matching value.
+//
+// Deprecated: use Next.
+func (ce *CronExpression) SetValue(timePart string, val int) *CronExpression {
+	switch timePart {
+	case "second":
+		ce.Second = val
+	case "minute":
+		ce.Minute = val
+	case "hour":
+		ce.Hour = val
+	case "dayofweek", "dayofmonth", "day":
+		timePart = "day"
+		ce.Day = val
+	case "month":
+		ce.Month = val
+	case "year":
+		ce.Year = val
+	}
+	for i, part := range _legacyParts {
+		if part != timePart || i + 1 == len(_legacyParts) {
+			continue
+		}
+		lower := _legacyParts[i + 1]
+		ce.SetValue(lower, _legacyMin[lower])
+		if check, move := ce.legacyFuncs(lower); check != nil && !check() {
+			move()
+		}
+	}
+	return ce
+}
+
+func (ce *CronExpression) legacyFuncs(timePart string) (func() bool, func() time.Time) {
+	switch timePart {
+	case "second":
+		return ce.CheckSecond, ce.MoveNextSecond
+	case "minute":
+		return ce.CheckMinute, ce.MoveNextMinute
+	case "hour":
+		return ce.CheckHour, ce.MoveNextHour
+	case "day":
+		return ce.CheckDay, ce.MoveNextDay
+	case "month":
+		return ce.CheckMonth, ce.MoveNextMonth
+	}
+	return ce.CheckYear, ce.MoveNextYear
+}
+
+// NextValue returns the value of nums following val, wrapping around to the first one, or -1 when nums is empty.
+//
+// Deprecated: use Next.
+func NextValue(ce *CronExpression, timePart string, val int, nums []int) int {
+	if len(nums) == 0 {
+		return -1
+	}
+	for i := 1; i < len(nums); i++ {
+		if val >= nums[i-1] && val < nums[i] {
+			return nums[i]
+		}
+	}
+	return nums[0]
+}
+
+// CreateMoveFunc returns a function moving the field timePart of ce to the next of the sorted values in slices.
+//
+// Deprecated: use Next.
+func CreateMoveFunc(ce *CronExpression, timePart string, slices []int) func() time.Time {
+	return func() time.Time {
+		nextVal := NextValue(ce, timePart, ce.GetValue(timePart), slices)
+		if nextVal == -1 {
+			return ce.ToTime()
+		}
+		ce.SetValue(timePart, nextVal)
+		return ce.ToTime()
+	}
+}
+
+// CreateCheckFunc returns a function reporting whether the field timePart of ce is one of slices.
+//
+// Deprecated: use Next.
+func CreateCheckFunc(ce *CronExpression, timePart string, slices []int) func() bool {
+	return func() bool {
+		return contains(slices, ce.GetValue(timePart))
+	}
+}
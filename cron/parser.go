@@ -0,0 +1,109 @@
+package cron
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Mode selects the cron expression format understood by a Parser.
+type Mode int
+
+const (
+	// QuartzMode reads "second minute hour dayofmonth month dayofweek [year]" with SUN=1 and one day field set to ?.
+	QuartzMode Mode = iota
+	// UnixMode reads Vixie cron "minute hour dayofmonth month dayofweek" with 0 and 7 as Sunday,
+	// a day matches either day field unless one of them starts with *.
+	UnixMode
+)
+
+// ParseMode returns the Mode named quartz or unix.
+func ParseMode(name string) (Mode, error) {
+	switch strings.ToLower(name) {
+	case "quartz":
+		return QuartzMode, nil
+	case "unix":
+		return UnixMode, nil
+	}
+	return QuartzMode, &ParseError{Line: name, Field: "CRON_MODE", Token: name, Reason: "mode must be quartz or unix"}
+}
+
+// Parser holds the settings used while parsing expressions and cron files, the zero value parses Quartz expressions.
+type Parser struct {
+	Mode Mode
+	Location *time.Location //time zone of expressions without a CRON_TZ prefix, nil means time.Local
+	Key string //job key H tokens are hashed from, ParseCronJob uses the job's id= attribute or its command
+	Env []string //NAME=value environment variables given to the commands of the jobs parsed, set by cron file lines
+	zoned bool //Location comes from the CRON_TZ= prefix of the expression being parsed
+}
+
+var _regexZone = regexp.MustCompile(`^(CRON_TZ|TZ)=(\S+)\s+(\S.*)$`) //eg: CRON_TZ=Asia/Shanghai 0 0 9 * * ?
+
+// splitZone strips a CRON_TZ= or TZ= prefix from line, returning a parser copy using that zone.
+func (p *Parser) splitZone(line string) (*Parser, string, error) {
+	match := _regexZone.FindStringSubmatch(line)
+	if match == nil {
+		return p, line, nil
+	}
+	loc, err := time.LoadLocation(match[2])
+	if err != nil {
+		return nil, line, &ParseError{Line: line, Field: match[1], Token: match[2], Reason: "unknown time zone"}
+	}
+	zp := *p
+	zp.Location = loc
+	zp.zoned = true
+	return &zp, match[3], nil
+}
+
+func (p *Parser) location() *time.Location {
+	if p.Location == nil {
+		return time.Local
+	}
+	return p.Location
+}
+
+func (p *Parser) setVariable(name string, value string) error {
+	//like Vixie cron, a value may be quoted to keep its leading or trailing spaces
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	switch name {
+	case "CRON_MODE":
+		mode, err := ParseMode(value)
+		if err != nil {
+			return err
+		}
+		p.Mode = mode
+		return nil
+	case "CRON_TZ":
+		loc, err := time.LoadLocation(value)
+		if err != nil {
+			return &ParseError{Line: name + "=" + value, Field: name, Token: value, Reason: "unknown time zone"}
+		}
+		p.Location = loc
+		return nil
+	}
+	p.Env = setEnv(p.Env, name, value)
+	return nil
+}
+
+// setEnv returns a copy of env with name set to value, so that jobs already parsed keep the environment they had.
+func setEnv(env []string, name string, value string) []string {
+	result := []string{}
+	for _, entry := range env {
+		if !strings.HasPrefix(entry, name + "=") {
+			result = append(result, entry)
+		}
+	}
+	return append(result, name + "=" + value)
+}
+
+// lookupEnv returns the value of name in env.
+func lookupEnv(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], name + "=") {
+			return env[i][len(name) + 1:], true
+		}
+	}
+	return "", false
+}
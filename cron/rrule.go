@@ -0,0 +1,590 @@
+package cron
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RRuleExpression fires at the occurrences of an RFC 5545 recurrence rule,
+// e.g. DTSTART:20200301T090000 RRULE:FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1.
+//
+// Occurrences are computed on the wall clock of Location with the daylight saving rules of CronExpression.
+// BYWEEKNO and BYYEARDAY are not supported.
+type RRuleExpression struct {
+	Rule string //the RRULE value, e.g. FREQ=DAILY;BYHOUR=9
+	Start time.Time //DTSTART, the first possible occurrence
+	Location *time.Location
+	freq int
+	interval int
+	count int //0 when unlimited
+	until time.Time //zero when unlimited
+	bySecond []int
+	byMinute []int
+	byHour []int
+	byDay []weekdayNum
+	byMonthDay []int
+	byMonth []int
+	bySetPos []int
+	weekStart time.Weekday
+	start time.Time //Start on the wall clock of Location
+	simple bool //every period has exactly one occurrence, so the n-th occurrence is in period n-1
+	mu sync.Mutex
+	mark rruleMark //with COUNT, where the last counting of occurrences stopped
+}
+
+// rruleMark is a period index with the number of occurrences in the periods before it.
+type rruleMark struct {
+	k int
+	n int
+}
+
+// weekdayNum is a BYDAY item such as MO, 1MO or -1FR, n is 0 when every such weekday matches.
+type weekdayNum struct {
+	n int
+	weekday time.Weekday
+}
+
+const (
+	_secondly = iota
+	_minutely
+	_hourly
+	_daily
+	_weekly
+	_monthly
+	_yearly
+)
+
+var _frequencies = map[string]int {
+	"SECONDLY": _secondly, "MINUTELY": _minutely, "HOURLY": _hourly, "DAILY": _daily,
+	"WEEKLY": _weekly, "MONTHLY": _monthly, "YEARLY": _yearly,
+}
+
+var _weekdays = map[string]time.Weekday {
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var _regexRRule = regexp.MustCompile(`^(DTSTART(?P<params>;[^:\s]*)?:(?P<start>\S+)\s+)?RRULE:(?P<rule>\S+)$`) //eg: DTSTART:20200301T090000 RRULE:FREQ=DAILY
+var _regexByDay = regexp.MustCompile(`^([+-]?[0-9]{1,2})?(MO|TU|WE|TH|FR|SA|SU)$`) //eg: MO, -1FR
+
+// _rruleLists are the BY* parts made of integers with their allowed range, negative values count from the end.
+var _rruleLists = map[string][]int {
+	"BYSECOND": []int { 0, 59},
+	"BYMINUTE": []int { 0, 59},
+	"BYHOUR": []int { 0, 23},
+	"BYMONTHDAY": []int { -31, 31},
+	"BYMONTH": []int { 1, 12},
+	"BYSETPOS": []int { -366, 366},
+}
+
+// TryParseRRule parses an RRULE schedule, see Parser.ParseRRule.
+func TryParseRRule(line string) (*RRuleExpression, error) {
+	return (&Parser{}).ParseRRule(line)
+}
+
+// ParseRRule parses "RRULE:<rule>" optionally preceded by "DTSTART[;TZID=<zone>]:<date-time> ". The rule is
+// expanded in the TZID zone of DTSTART unless the line has a CRON_TZ= prefix, and otherwise in the parser's Location.
+// A DTSTART without a Z suffix or a TZID is in that Location, without a DTSTART the rule starts at midnight of the
+// current day.
+func (p *Parser) ParseRRule(line string) (*RRuleExpression, error) {
+	match := _regexRRule.FindStringSubmatch(line)
+	if match == nil {
+		return nil, &ParseError{Line: line, Reason: "expected [DTSTART:<time>] RRULE:<rule>"}
+	}
+	loc := p.location()
+	if zone, err := tzidLocation(match[2]); err != nil {
+		return nil, &ParseError{Line: line, Field: "TZID", Token: match[2], Reason: "unknown time zone"}
+	} else if zone != nil && !p.zoned {
+		loc = zone
+	}
+	now := time.Now().In(loc)
+	r := &RRuleExpression{Rule: match[4], Location: loc, interval: 1, weekStart: time.Monday,
+		Start: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)}
+	if match[1] != "" {
+		start, _, err := parseICalTime(match[3], match[2], loc)
+		if err != nil {
+			return nil, &ParseError{Line: line, Field: "DTSTART", Token: match[3], Reason: "must be a date or date-time such as 20200301T090000"}
+		}
+		r.Start = start
+	}
+	r.freq = -1
+	for _, part := range strings.Split(r.Rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, &ParseError{Line: line, Field: "RRULE", Token: part, Reason: "must be NAME=value"}
+		}
+		if err := r.setPart(kv[0], kv[1], loc); err != nil {
+			return nil, &ParseError{Line: line, Field: kv[0], Token: kv[1], Reason: err.Error()}
+		}
+	}
+	if r.freq < 0 {
+		return nil, &ParseError{Line: line, Field: "FREQ", Reason: "FREQ is required"}
+	}
+	if r.count > 0 && !r.until.IsZero() {
+		return nil, &ParseError{Line: line, Field: "COUNT", Reason: "COUNT and UNTIL must not be used together"}
+	}
+	r.start = toWall(r.Start, loc).Truncate(time.Second)
+	r.simple = len(r.bySecond) + len(r.byMinute) + len(r.byHour) + len(r.byDay) + len(r.byMonthDay) + len(r.byMonth) +
+		len(r.bySetPos) == 0 && (r.freq <= _weekly || r.start.Day() <= 28)
+	return r, nil
+}
+
+func (r *RRuleExpression) setPart(name, value string, loc *time.Location) error {
+	if limits, exists := _rruleLists[name]; exists {
+		values := []int{}
+		for _, item := range strings.Split(value, ",") {
+			num, err := strconv.Atoi(item)
+			if err != nil || num < limits[0] || num > limits[1] || (num == 0 && limits[0] < 0) {
+				return fmt.Errorf("values must be between %d and %d", limits[0], limits[1])
+			}
+			values = append(values, num)
+		}
+		switch name {
+		case "BYSECOND":
+			r.bySecond = values
+		case "BYMINUTE":
+			r.byMinute = values
+		case "BYHOUR":
+			r.byHour = values
+		case "BYMONTHDAY":
+			r.byMonthDay = values
+		case "BYMONTH":
+			r.byMonth = values
+		case "BYSETPOS":
+			r.bySetPos = values
+		}
+		return nil
+	}
+	switch name {
+	case "FREQ":
+		freq, exists := _frequencies[value]
+		if !exists {
+			return fmt.Errorf("unknown frequency")
+		}
+		r.freq = freq
+	case "INTERVAL", "COUNT":
+		num, err := strconv.Atoi(value)
+		if err != nil || num < 1 {
+			return fmt.Errorf("must be a positive number")
+		}
+		if name == "INTERVAL" {
+			r.interval = num
+		} else {
+			r.count = num
+		}
+	case "UNTIL":
+		until, allDay, err := parseICalTime(value, "", loc)
+		if err != nil {
+			return fmt.Errorf("must be a date or date-time such as 20201231T235959Z")
+		}
+		if allDay {
+			until = until.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		r.until = until
+	case "BYDAY":
+		for _, item := range strings.Split(value, ",") {
+			match := _regexByDay.FindStringSubmatch(item)
+			if match == nil {
+				return fmt.Errorf("items must be weekdays such as MO or -1FR")
+			}
+			n, _ := strconv.Atoi(match[1])
+			r.byDay = append(r.byDay, weekdayNum{n: n, weekday: _weekdays[match[2]]})
+		}
+	case "WKST":
+		weekday, exists := _weekdays[value]
+		if !exists {
+			return fmt.Errorf("must be a weekday such as MO")
+		}
+		r.weekStart = weekday
+	default:
+		return fmt.Errorf("unsupported rule part")
+	}
+	return nil
+}
+
+// Next returns the first occurrence strictly after t, false when the rule has no more occurrences.
+func (r *RRuleExpression) Next(t time.Time) (time.Time, bool) {
+	k, n := r.periodOf(toWall(t, r.location())), 0
+	if k < 0 {
+		k = 0
+	}
+	if r.count > 0 {
+		if k, n = r.counted(k); n >= r.count {
+			return time.Time{}, false
+		}
+	}
+	next, found := time.Time{}, false
+	r.scan(k, n, func(occurrence time.Time) bool {
+		if occurrence.After(t) {
+			next, found = occurrence, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// scan calls fn with the occurrences of the periods from k on in order, until fn returns false or the rule ends.
+// n is the number of occurrences before period k.
+func (r *RRuleExpression) scan(k int, n int, fn func(occurrence time.Time) bool) {
+	loc := r.location()
+	for ; r.periodStart(k).Year() <= _timeRange["year"][1]; k++ {
+		if skip, ok := r.skipPeriod(k, 1); ok {
+			k = skip - 1
+			continue
+		}
+		for _, w := range r.occurrences(k) {
+			if w.Before(r.start) {
+				continue
+			}
+			n++
+			occurrence := fromWall(w, loc)
+			if (r.count > 0 && n > r.count) || (!r.until.IsZero() && occurrence.After(r.until)) {
+				return
+			}
+			if !fn(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// Prev returns the last occurrence strictly before t, false when the rule had no occurrence before t.
+func (r *RRuleExpression) Prev(t time.Time) (time.Time, bool) {
+	loc := r.location()
+	//occurrences on the wall clock up to 3 hours after t's may have happened before t in a repeated hour
+	k := r.periodOf(toWall(t, loc).Add(3 * time.Hour))
+	over := 0 //occurrences at the end of period k past COUNT
+	if r.count > 0 {
+		if end, n := r.counted(k + 1); n >= r.count {
+			k, over = end - 1, n - r.count
+		}
+	}
+	for ; k >= 0; k-- {
+		if skip, ok := r.skipPeriod(k, -1); ok {
+			k = skip + 1
+			continue
+		}
+		occurrences := r.occurrences(k)
+		for i := len(occurrences) - 1 - over; i >= 0; i-- {
+			w := occurrences[i]
+			if w.Before(r.start) {
+				return time.Time{}, false
+			}
+			prev := fromWall(w, loc)
+			if prev.Before(t) && (r.until.IsZero() || !prev.After(r.until)) {
+				return prev, true
+			}
+		}
+		over = 0
+	}
+	return time.Time{}, false
+}
+
+// counted returns k and the number of occurrences in the periods before k, or when COUNT is reached before period k
+// the period after the COUNT-th occurrence and the occurrences up to the end of its period. The periods counted are
+// remembered, so moving on in time only counts the periods since the last call.
+func (r *RRuleExpression) counted(k int) (int, int) {
+	if k <= 0 {
+		return 0, 0
+	}
+	if r.simple {
+		if k > r.count {
+			return r.count, r.count
+		}
+		return k, k
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.mark
+	if m.k > k {
+		m = rruleMark{}
+	}
+	for m.k < k && m.n < r.count && r.periodStart(m.k).Year() <= _timeRange["year"][1] {
+		if skip, ok := r.skipPeriod(m.k, 1); ok {
+			if m.k = skip; m.k > k {
+				m.k = k
+			}
+			continue
+		}
+		for _, w := range r.occurrences(m.k) {
+			if !w.Before(r.start) {
+				m.n++
+			}
+		}
+		m.k++
+	}
+	if m.k > r.mark.k {
+		r.mark = m
+	}
+	return m.k, m.n
+}
+
+func (r *RRuleExpression) location() *time.Location {
+	if r.Location == nil {
+		return time.Local
+	}
+	return r.Location
+}
+
+// periodStart returns the wall clock start of the k-th period of the rule, a period spans INTERVAL units of FREQ.
+func (r *RRuleExpression) periodStart(k int) time.Time {
+	s := r.start
+	step := k * r.interval
+	switch r.freq {
+	case _yearly:
+		return time.Date(s.Year() + step, 1, 1, 0, 0, 0, 0, time.UTC)
+	case _monthly:
+		return time.Date(s.Year(), s.Month() + time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+	case _weekly:
+		offset := (int(s.Weekday()) - int(r.weekStart) + 7) % 7
+		return time.Date(s.Year(), s.Month(), s.Day() - offset + 7 * step, 0, 0, 0, 0, time.UTC)
+	case _daily:
+		return time.Date(s.Year(), s.Month(), s.Day() + step, 0, 0, 0, 0, time.UTC)
+	case _hourly:
+		return time.Date(s.Year(), s.Month(), s.Day(), s.Hour() + step, 0, 0, 0, time.UTC)
+	case _minutely:
+		return time.Date(s.Year(), s.Month(), s.Day(), s.Hour(), s.Minute() + step, 0, 0, time.UTC)
+	}
+	return s.Add(time.Duration(step) * time.Second)
+}
+
+// periodOf returns the index of the period containing the wall clock time w, negative before the first period.
+func (r *RRuleExpression) periodOf(w time.Time) int {
+	s := r.periodStart(0)
+	units := 0
+	switch r.freq {
+	case _yearly:
+		units = w.Year() - s.Year()
+	case _monthly:
+		units = (w.Year() - s.Year()) * 12 + int(w.Month()) - int(s.Month())
+	case _weekly:
+		units = floorDiv(int(w.Sub(s) / (24 * time.Hour)), 7)
+	case _daily:
+		units = int(w.Sub(s) / (24 * time.Hour))
+	case _hourly:
+		units = int(w.Sub(s) / time.Hour)
+	case _minutely:
+		units = int(w.Sub(s) / time.Minute)
+	default:
+		units = int(w.Sub(s) / time.Second)
+	}
+	if w.Before(s) && units >= 0 {
+		units = -1
+	}
+	return floorDiv(units, r.interval)
+}
+
+// skipPeriod lets sub-daily rules jump over days, or hours for MINUTELY and SECONDLY, that BY* parts exclude.
+// It returns the first period of the next (dir 1) or last period of the previous (dir -1) day or hour.
+func (r *RRuleExpression) skipPeriod(k int, dir int) (int, bool) {
+	if r.freq >= _daily {
+		return 0, false
+	}
+	p := r.periodStart(k)
+	day := time.Date(p.Year(), p.Month(), p.Day(), 0, 0, 0, 0, time.UTC)
+	if !r.matchDay(day) {
+		if dir > 0 {
+			return r.periodOf(day.AddDate(0, 0, 1).Add(-time.Second)) + 1, true
+		}
+		return r.periodOf(day.Add(-time.Second)), true
+	}
+	if r.freq < _hourly && len(r.byHour) > 0 && !contains(r.byHour, p.Hour()) {
+		hour := time.Date(p.Year(), p.Month(), p.Day(), p.Hour(), 0, 0, 0, time.UTC)
+		if dir > 0 {
+			return r.periodOf(hour.Add(time.Hour - time.Second)) + 1, true
+		}
+		return r.periodOf(hour.Add(-time.Second)), true
+	}
+	return 0, false
+}
+
+// occurrences returns the sorted wall clock occurrences of the k-th period before DTSTART, COUNT and UNTIL apply.
+func (r *RRuleExpression) occurrences(k int) []time.Time {
+	p := r.periodStart(k)
+	s := r.start
+	hours, minutes, seconds := r.byHour, r.byMinute, r.bySecond
+	if len(hours) == 0 {
+		hours = []int{s.Hour()}
+	}
+	if len(minutes) == 0 {
+		minutes = []int{s.Minute()}
+	}
+	if len(seconds) == 0 {
+		seconds = []int{s.Second()}
+	}
+	//sub-daily frequencies take the larger units from the period, the BY* parts of those units only limit
+	switch r.freq {
+	case _hourly:
+		hours = limit(hours, r.byHour, p.Hour())
+	case _minutely:
+		hours = limit(hours, r.byHour, p.Hour())
+		minutes = limit(minutes, r.byMinute, p.Minute())
+	case _secondly:
+		hours = limit(hours, r.byHour, p.Hour())
+		minutes = limit(minutes, r.byMinute, p.Minute())
+		seconds = limit(seconds, r.bySecond, p.Second())
+	}
+	occurrences := []time.Time{}
+	for _, day := range r.days(p) {
+		for _, hour := range hours {
+			for _, minute := range minutes {
+				for _, second := range seconds {
+					occurrences = append(occurrences, time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, time.UTC))
+				}
+			}
+		}
+	}
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Before(occurrences[j]) })
+	if len(r.bySetPos) == 0 {
+		return occurrences
+	}
+	selected := []time.Time{}
+	for i, occurrence := range occurrences {
+		for _, pos := range r.bySetPos {
+			if pos == i + 1 || pos == i - len(occurrences) {
+				selected = append(selected, occurrence)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// limit returns []int{value} when the BY* part by is empty or contains value, nil otherwise.
+func limit(values []int, by []int, value int) []int {
+	if len(by) > 0 && !contains(by, value) {
+		return nil
+	}
+	return []int{value}
+}
+
+// days returns the days of the period starting at p, following the expand and limit rules of RFC 5545.
+func (r *RRuleExpression) days(p time.Time) []time.Time {
+	s := r.start
+	days := []time.Time{}
+	switch r.freq {
+	case _yearly:
+		months := r.byMonth
+		if len(months) == 0 && (len(r.byMonthDay) > 0 || len(r.byDay) == 0) {
+			months = []int{int(s.Month())}
+			if len(r.byMonthDay) > 0 {
+				months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		if len(months) == 0 {
+			//BYDAY alone counts weekdays within the year
+			return r.expandByDay(p, p.AddDate(1, 0, 0))
+		}
+		for _, month := range months {
+			first := time.Date(p.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			days = append(days, r.monthDays(first)...)
+		}
+	case _monthly:
+		if len(r.byMonth) > 0 && !contains(r.byMonth, int(p.Month())) {
+			return nil
+		}
+		days = r.monthDays(p)
+	case _weekly:
+		for i := 0; i < 7; i++ {
+			day := p.AddDate(0, 0, i)
+			if (len(r.byMonth) == 0 || contains(r.byMonth, int(day.Month()))) && r.matchWeekday(day, s.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	default:
+		day := time.Date(p.Year(), p.Month(), p.Day(), 0, 0, 0, 0, time.UTC)
+		if r.matchDay(day) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthDays returns the days of the month starting at first selected by BYMONTHDAY, BYDAY or the day of DTSTART.
+func (r *RRuleExpression) monthDays(first time.Time) []time.Time {
+	last := LastDay(first.Year(), first.Month())
+	days := []time.Time{}
+	switch {
+	case len(r.byMonthDay) > 0:
+		for day := 1; day <= last; day++ {
+			t := first.AddDate(0, 0, day - 1)
+			if (contains(r.byMonthDay, day) || contains(r.byMonthDay, day - last - 1)) && r.matchWeekday(t, -1) {
+				days = append(days, t)
+			}
+		}
+	case len(r.byDay) > 0:
+		days = r.expandByDay(first, first.AddDate(0, 1, 0))
+	case r.start.Day() <= last:
+		days = append(days, first.AddDate(0, 0, r.start.Day() - 1))
+	}
+	return days
+}
+
+// expandByDay returns the days from start up to end matching BYDAY, where 2MO is the second and -1MO the last Monday.
+func (r *RRuleExpression) expandByDay(start, end time.Time) []time.Time {
+	days := []time.Time{}
+	for _, wn := range r.byDay {
+		matches := []time.Time{}
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == wn.weekday {
+				matches = append(matches, day)
+			}
+		}
+		switch {
+		case wn.n == 0:
+			days = append(days, matches...)
+		case wn.n > 0 && wn.n <= len(matches):
+			days = append(days, matches[wn.n - 1])
+		case wn.n < 0 && -wn.n <= len(matches):
+			days = append(days, matches[len(matches) + wn.n])
+		}
+	}
+	return days
+}
+
+// matchDay reports whether BYMONTH, BYMONTHDAY and BYDAY all allow the day, used when they limit a period.
+func (r *RRuleExpression) matchDay(day time.Time) bool {
+	if len(r.byMonth) > 0 && !contains(r.byMonth, int(day.Month())) {
+		return false
+	}
+	if len(r.byMonthDay) > 0 {
+		last := LastDay(day.Year(), day.Month())
+		if !contains(r.byMonthDay, day.Day()) && !contains(r.byMonthDay, day.Day() - last - 1) {
+			return false
+		}
+	}
+	return r.matchWeekday(day, -1)
+}
+
+// matchWeekday reports whether BYDAY, ignoring ordinals, allows the day, or when BYDAY is empty whether the day
+// falls on fallback, where -1 allows any day.
+func (r *RRuleExpression) matchWeekday(day time.Time, fallback time.Weekday) bool {
+	if len(r.byDay) == 0 {
+		return fallback < 0 || day.Weekday() == fallback
+	}
+	for _, wn := range r.byDay {
+		if wn.weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the DTSTART and RRULE the expression was parsed from.
+func (r *RRuleExpression) String() string {
+	return fmt.Sprintf("DTSTART;TZID=%s:%s RRULE:%s", r.location(), r.start.Format("20060102T150405"), r.Rule)
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 && a % b != 0 {
+		return a / b - 1
+	}
+	return a / b
+}
@@ -1,5 +1,34 @@
 package cron
 
+import (
+	"strings"
+	"time"
+)
+
+// Expression is what a CronJob in the Schedule heap follows, implemented by *CronExpression, *EveryExpression
+// and *RRuleExpression.
+type Expression interface {
+	// Next returns the first fire time strictly after t, false when there is none.
+	Next(t time.Time) (time.Time, bool)
+	// Prev returns the last fire time strictly before t, false when there is none.
+	Prev(t time.Time) (time.Time, bool)
+}
+
+// TryParseExpression parses an @every schedule, an RRULE schedule or a cron expression.
+func TryParseExpression(line string) (Expression, error) {
+	return (&Parser{}).ParseExpression(line)
+}
+
+// ParseExpression parses an @every schedule, an RRULE schedule or a cron expression in the parser's Mode.
+func (p *Parser) ParseExpression(line string) (Expression, error) {
+	if zp, rest, err := p.splitZone(line); err == nil && strings.HasPrefix(rest, "@every") {
+		return zp.ParseEvery(rest)
+	} else if err == nil && (strings.HasPrefix(rest, "RRULE:") || strings.HasPrefix(rest, "DTSTART")) {
+		return zp.ParseRRule(rest)
+	}
+	return p.ParseCronExpression(line)
+}
+
 type Schedule []*CronJob
 
 func (cj *Schedule) Len() int{
@@ -4,6 +4,7 @@ import (
 	"./cron"
 	"./util"
 	"container/heap"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -14,20 +15,38 @@ var schedule *cron.Schedule
 var jobChan chan *cron.CronJob
 
 func main() {
-	if len(os.Args) < 2 {
+	modeName := flag.String("mode", "quartz", "cron expression format of the cron file: quartz or unix")
+	flag.Parse()
+	if flag.NArg() < 1 {
 		fmt.Println("miss cron file")
 		os.Exit(-1)
 	}
+	mode, err := cron.ParseMode(*modeName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
 	schedule = &cron.Schedule{}
 	heap.Init(schedule)
-	cron_file :=  os.Args[1]
-	cronJobs := cron.ParseCronFile(cron_file)
+	cron_file :=  flag.Arg(0)
+	parser := &cron.Parser{Mode: mode}
+	cronJobs, err := parser.ParseCronFile(cron_file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
 	jobChan = make(chan *cron.CronJob, len(cronJobs))
 	go StartSchedule()
 	go PushCronJob()
 	for _, cronJob := range cronJobs {
+		if cronJob.Reboot {
+			go RunCronJob(cronJob)
+			continue
+		}
 		cronJob.MoveNext()
-		jobChan <- cronJob
+		if !cronJob.IsEnd {
+			jobChan <- cronJob
+		}
 	}
 	c := make(chan os.Signal, 0)
 	signal.Notify(c, os.Interrupt, os.Kill)
@@ -55,9 +74,10 @@ func StartSchedule() {
 		ts := (<- timer.C).Unix()
 		util.Log("Tick")
 		runCronJobs := []*cron.CronJob{}
-		for schedule.Len() > 0 && (*schedule)[0].NextRunTime().Unix() == ts {
+		//jobs overdue after a late tick or a suspend fire once, MoveNext skips the fire times missed meanwhile
+		for schedule.Len() > 0 && (*schedule)[0].NextRunTime().Unix() <= ts {
 			cj := heap.Pop(schedule).(*cron.CronJob)
-			go cj.Run()
+			go RunCronJob(cj)
 			cj.MoveNext()
 			if !cj.IsEnd {
 				runCronJobs = append(runCronJobs, cj)
@@ -66,3 +86,12 @@ func StartSchedule() {
 		go PushCronJob2Chan(runCronJobs...)
 	}
 }
+
+// RunCronJob runs the job once its random jitter delay has passed, the fire times of the job are not affected.
+func RunCronJob(cj *cron.CronJob) {
+	if delay := cj.JitterDelay(); delay > 0 {
+		util.Log("Delay Job: %s. Jitter: %s.", cj.Desc, delay)
+		time.Sleep(delay)
+	}
+	cj.Run()
+}
@@ -2,7 +2,13 @@ package test
 
 import (
 	"../cron"
+	"container/heap"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 	"time"
 )
@@ -1223,3 +1229,1340 @@ func Test_edge(t *testing.T) {
 	}
 	fmt.Println("Test_edge end")
 }
+
+var parseErrorCases = map[string]string{
+	"0 0 0 * *":        "",
+	"60 * * * * ? *":   "second",
+	"0 0 24 * * ? *":   "hour",
+	"0 0 0 * * * *":    "dayofmonth",
+	"0 0 0 ? * 8 *":    "dayofweek",
+	"0 0 0 ? 1-13 * *": "month",
+	"0 0 0 1 * ? 2300": "year",
+	"0 0 0 ? * 3#6 *":  "dayofweek",
+	"0 abc 0 1 * ? *":  "minute",
+}
+
+func Test_parseError(t *testing.T) {
+	fmt.Println("Test_parseError start")
+	for expression, field := range parseErrorCases {
+		fmt.Printf("Test %s\n", expression)
+		_, err := cron.TryParseCronExpression(expression)
+		pe, ok := err.(*cron.ParseError)
+		if !ok {
+			t.Fatal(expression, err)
+		}
+		if pe.Field != field {
+			fmt.Printf("want: %s, actual: %s\n", field, pe.Field)
+			t.Fatal(pe)
+		}
+	}
+	if _, err := cron.TryParseCronData("0 0 0 * * ? echo ok\n0 0 61 * * ? echo bad"); err == nil || err.(*cron.ParseError).LineNo != 2 {
+		t.Fatal(err)
+	}
+	fmt.Println("Test_parseError end")
+}
+
+var nextPrevCases = map[string][]string{
+	"0/20 * * * * ? *":     []string{"2030-03-06 20:36:20", "2030-03-06 20:36:40", "2030-03-06 20:37:00", "2030-03-06 20:37:20", "2030-03-06 20:37:40", "2030-03-06 20:38:00"},
+	"0 15 10 ? * 6L *":     []string{"2030-03-29 10:15:00", "2030-04-26 10:15:00", "2030-05-31 10:15:00", "2030-06-28 10:15:00", "2030-07-26 10:15:00", "2030-08-30 10:15:00"},
+	"0 15 10 ? * 6#3 *":    []string{"2030-03-15 10:15:00", "2030-04-19 10:15:00", "2030-05-17 10:15:00", "2030-06-21 10:15:00", "2030-07-19 10:15:00", "2030-08-16 10:15:00"},
+	"0 15 10 31-3 1-5 ? *": []string{"2030-03-31 10:15:00", "2030-04-01 10:15:00", "2030-04-02 10:15:00", "2030-04-03 10:15:00", "2030-05-01 10:15:00", "2030-05-02 10:15:00"},
+	"0 0 0 LW * ? *":       []string{"2030-03-29 00:00:00", "2030-04-30 00:00:00", "2030-05-31 00:00:00", "2030-06-28 00:00:00", "2030-07-31 00:00:00", "2030-08-30 00:00:00"},
+	"0 10,44 14 ? 3 4":     []string{"2030-03-13 14:10:00", "2030-03-13 14:44:00", "2030-03-20 14:10:00", "2030-03-20 14:44:00", "2030-03-27 14:10:00", "2030-03-27 14:44:00"},
+}
+
+func Test_nextPrev(t *testing.T) {
+	fmt.Println("Test_nextPrev start")
+	base := time.Date(2030, 03, 06, 20, 36, 0, 0, time.Local)
+	for expression, values := range nextPrevCases {
+		fmt.Printf("Test %s\n", expression)
+		c := cron.ParseCronExpression(expression)
+		wants := []time.Time{}
+		for _, value := range values {
+			want, _ := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+			wants = append(wants, want)
+		}
+		done := make(chan bool)
+		for g := 0; g < 4; g++ {
+			go func() {
+				prev := base
+				for _, want := range wants {
+					next, ok := c.Next(prev)
+					if !ok || !next.Equal(want) {
+						t.Error(expression, next, want)
+					}
+					prev = next
+				}
+				done <- true
+			}()
+		}
+		for g := 0; g < 4; g++ {
+			<-done
+		}
+		for i := len(wants) - 1; i > 0; i-- {
+			prev, ok := c.Prev(wants[i])
+			if !ok || !prev.Equal(wants[i-1]) {
+				t.Fatal(expression, prev, wants[i-1])
+			}
+		}
+	}
+	if _, ok := cron.ParseCronExpression("0 0 0 1 1 ? *").Next(time.Date(2300, 1, 1, 0, 0, 0, 0, time.Local)); ok {
+		t.Fatal("expression should be exhausted")
+	}
+	fmt.Println("Test_nextPrev end")
+}
+
+var nameCases = map[string]string{
+	"0 0 9 ? * MON-FRI":          "0 0 9 ? * 2-6",
+	"0 0 0 1 JAN,JUL ? *":        "0 0 0 1 1,7 ? *",
+	"0 0 0 1 mar-nov/2 ? *":      "0 0 0 1 3-11/2 ? *",
+	"0 15 10 ? * FRI#3 *":        "0 15 10 ? * 6#3 *",
+	"0 15 10 ? * friL *":         "0 15 10 ? * 6L *",
+	"0 0 12 ? Dec Sat,sun,Wed *": "0 0 12 ? 12 7,1,4 *",
+}
+
+func Test_names(t *testing.T) {
+	fmt.Println("Test_names start")
+	for expression, numeric := range nameCases {
+		fmt.Printf("Test %s\n", expression)
+		named := cron.ParseCronExpression(expression)
+		want := cron.ParseCronExpression(numeric)
+		prev := baseTime
+		for i := 0; i < 20; i++ {
+			actual, _ := named.Next(prev)
+			next, _ := want.Next(prev)
+			if !actual.Equal(next) {
+				fmt.Printf("want: %s, actual: %s\n", next, actual)
+				t.Fatal(expression)
+			}
+			prev = next
+		}
+	}
+	if _, err := cron.TryParseCronExpression("0 0 0 ? JAN MOX *"); err == nil {
+		t.Fatal("MOX should be rejected")
+	}
+	fmt.Println("Test_names end")
+}
+
+var macroCases = map[string]string{
+	"@yearly":   "0 0 0 1 1 ? *",
+	"@annually": "0 0 0 1 1 ? *",
+	"@monthly":  "0 0 0 1 * ? *",
+	"@weekly":   "0 0 0 ? * SUN *",
+	"@daily":    "0 0 0 * * ? *",
+	"@midnight": "0 0 0 * * ? *",
+	"@hourly":   "0 0 * * * ? *",
+}
+
+func Test_macros(t *testing.T) {
+	fmt.Println("Test_macros start")
+	for macro, expression := range macroCases {
+		fmt.Printf("Test %s\n", macro)
+		c := cron.ParseCronExpression(macro)
+		want := cron.ParseCronExpression(expression)
+		prev := baseTime
+		for i := 0; i < 5; i++ {
+			actual, _ := c.Next(prev)
+			next, _ := want.Next(prev)
+			if !actual.Equal(next) {
+				fmt.Printf("want: %s, actual: %s\n", next, actual)
+				t.Fatal(macro)
+			}
+			prev = next
+		}
+	}
+	cronJobs := cron.ParseCronData("# comment\n@reboot echo start\n\n@daily echo daily")
+	if len(cronJobs) != 2 || !cronJobs[0].Reboot || cronJobs[0].Desc != "echo start" || cronJobs[1].Reboot {
+		t.Fatal(cronJobs)
+	}
+	if _, ok := cronJobs[0].Next(baseTime); ok {
+		t.Fatal("@reboot should never fire on schedule")
+	}
+	if _, err := cron.TryParseCronExpression("@fortnightly"); err == nil {
+		t.Fatal("@fortnightly should be rejected")
+	}
+	fmt.Println("Test_macros end")
+}
+
+func Test_every(t *testing.T) {
+	fmt.Println("Test_every start")
+	e, err := cron.TryParseEvery("@every 1m30s from 2020-03-06T20:36:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := []string{"2020-03-06 20:36:00", "2020-03-06 20:37:30", "2020-03-06 20:39:00", "2020-03-06 20:40:30"}
+	prev := baseTime.Add(-time.Second)
+	for _, want := range wants {
+		next, ok := e.Next(prev)
+		actual := next.Format("2006-01-02 15:04:05")
+		if !ok || actual != want {
+			fmt.Printf("want: %s, actual: %s\n", want, actual)
+			t.Fatal(e)
+		}
+		if p, ok := e.Prev(next.Add(time.Second)); !ok || !p.Equal(next) {
+			t.Fatal(p, next)
+		}
+		prev = next
+	}
+	if _, ok := e.Prev(baseTime); ok {
+		t.Fatal("no fire time before the anchor")
+	}
+	cj := cron.ParseCronJob("@every 2h30m echo every")
+	first := cj.MoveNext()
+	if second := cj.MoveNext(); second.Sub(first) != 150*time.Minute || cj.Desc != "echo every" {
+		t.Fatal(first, second, cj.Desc)
+	}
+	for line, reason := range map[string]string{
+		"@every 500ms":             "must be a whole number of seconds",
+		"@every 1500ms":            "must be a whole number of seconds",
+		"@every 0s":                "must be positive",
+		"@every -1s":               "must be positive",
+		"@every 1x":                "must be a duration such as 1m30s",
+		"@every 1m from yesterday": "must be an RFC 3339 time",
+	} {
+		if _, err := cron.TryParseEvery(line); err == nil || err.(*cron.ParseError).Reason != reason {
+			t.Fatal(line, err)
+		}
+	}
+	if _, err := cron.TryEvery(0, baseTime); err == nil {
+		t.Fatal("zero interval accepted")
+	}
+	if _, ok := (&cron.EveryExpression{Anchor: baseTime}).Next(baseTime); ok {
+		t.Fatal("zero interval fires")
+	}
+	far := time.Date(2400, 1, 1, 0, 0, 0, 0, time.Local)
+	if next, ok := e.Next(far); ok {
+		t.Fatal("next fire time beyond time.Duration", next)
+	}
+	if p, ok := e.Prev(far); ok {
+		t.Fatal("previous fire time beyond time.Duration", p)
+	}
+	fmt.Println("Test_every end")
+}
+
+var unixCases = map[string][]string{
+	"30 9 * * 1-5": []string{
+		"2037-03-09 09:30:00",
+		"2037-03-10 09:30:00",
+		"2037-03-11 09:30:00",
+		"2037-03-12 09:30:00",
+		"2037-03-13 09:30:00",
+		"2037-03-16 09:30:00",
+	},
+	"0 0 1,15 * 0": []string{
+		"2037-03-08 00:00:00",
+		"2037-03-15 00:00:00",
+		"2037-03-22 00:00:00",
+		"2037-03-29 00:00:00",
+		"2037-04-01 00:00:00",
+		"2037-04-05 00:00:00",
+	},
+	"0 12 1-31/10 * sat,7": []string{
+		"2037-03-07 12:00:00",
+		"2037-03-08 12:00:00",
+		"2037-03-11 12:00:00",
+		"2037-03-14 12:00:00",
+		"2037-03-15 12:00:00",
+		"2037-03-21 12:00:00",
+	},
+	"0 12 */10 * sat,7": []string{
+		"2037-03-21 12:00:00",
+		"2037-04-11 12:00:00",
+		"2037-05-31 12:00:00",
+	},
+	"*/30 22 * 3 *": []string{
+		"2037-03-06 22:00:00",
+		"2037-03-06 22:30:00",
+		"2037-03-07 22:00:00",
+	},
+}
+
+func Test_unix(t *testing.T) {
+	fmt.Println("Test_unix start")
+	parser := &cron.Parser{Mode: cron.UnixMode}
+	base := time.Date(2037, 03, 06, 20, 36, 0, 0, time.Local)
+	for expression, wants := range unixCases {
+		fmt.Printf("Test %s\n", expression)
+		c, err := parser.ParseCronExpression(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev := base
+		for _, want := range wants {
+			next, _ := c.Next(prev)
+			actual := next.Format("2006-01-02 15:04:05")
+			if actual != want {
+				fmt.Printf("want: %s, actual: %s\n", want, actual)
+				t.Fatal(expression)
+			}
+			prev = next
+		}
+	}
+	for _, expression := range []string{"0 0 ? * 1", "0 0 L * *", "0 0 * * 8", "0 0 0 * * ?"} {
+		if _, err := parser.ParseCronExpression(expression); err == nil {
+			t.Fatal(expression)
+		}
+	}
+	cronJobs, err := cron.TryParseCronData("0 0 0 * * ? echo quartz\nCRON_MODE=unix\n0 0 * * * echo unix\n@daily echo daily")
+	if err != nil || len(cronJobs) != 3 {
+		t.Fatal(err, cronJobs)
+	}
+	fmt.Println("Test_unix end")
+}
+
+var weekdayCases = map[string][]string{
+	"0 0 9 15W * ? *": []string{
+		"2037-03-16 09:00:00",
+		"2037-04-15 09:00:00",
+		"2037-05-15 09:00:00",
+		"2037-06-15 09:00:00",
+		"2037-07-15 09:00:00",
+		"2037-08-14 09:00:00",
+	},
+	"0 0 9 1W * ? *": []string{
+		"2037-04-01 09:00:00",
+		"2037-05-01 09:00:00",
+		"2037-06-01 09:00:00",
+		"2037-07-01 09:00:00",
+		"2037-08-03 09:00:00",
+		"2037-09-01 09:00:00",
+	},
+	"0 0 9 31W * ? *": []string{
+		"2037-03-31 09:00:00",
+		"2037-05-29 09:00:00",
+		"2037-07-31 09:00:00",
+		"2037-08-31 09:00:00",
+		"2037-10-30 09:00:00",
+		"2037-12-31 09:00:00",
+	},
+}
+
+func Test_weekday(t *testing.T) {
+	fmt.Println("Test_weekday start")
+	base := time.Date(2037, 03, 06, 20, 36, 0, 0, time.Local)
+	for expression, wants := range weekdayCases {
+		fmt.Printf("Test %s\n", expression)
+		c := cron.ParseCronExpression(expression)
+		prev := base
+		for _, want := range wants {
+			next, _ := c.Next(prev)
+			actual := next.Format("2006-01-02 15:04:05")
+			if actual != want {
+				fmt.Printf("want: %s, actual: %s\n", want, actual)
+				t.Fatal(expression)
+			}
+			prev = next
+		}
+	}
+	if _, err := cron.TryParseCronExpression("0 0 9 32W * ? *"); err == nil {
+		t.Fatal("32W should be rejected")
+	}
+	fmt.Println("Test_weekday end")
+}
+
+var lastDayCases = map[string][]string{
+	"0 0 0 L-3 * ? *": []string{
+		"2039-12-28 00:00:00",
+		"2040-01-28 00:00:00",
+		"2040-02-26 00:00:00",
+		"2040-03-28 00:00:00",
+	},
+	"0 0 0 L-1W * ? *": []string{
+		"2039-12-30 00:00:00",
+		"2040-01-30 00:00:00",
+		"2040-02-28 00:00:00",
+		"2040-03-30 00:00:00",
+	},
+	"0 0 0 LW-2 * ? *": []string{
+		"2039-12-28 00:00:00",
+		"2040-01-27 00:00:00",
+		"2040-02-27 00:00:00",
+		"2040-03-28 00:00:00",
+	},
+	"0 0 0 L-3 2 ? *": []string{
+		"2040-02-26 00:00:00",
+		"2041-02-25 00:00:00",
+	},
+	"0 0 0 L-1W 2 ? *": []string{
+		"2040-02-28 00:00:00",
+		"2041-02-27 00:00:00",
+	},
+	"0 0 0 LW-2 2 ? *": []string{
+		"2040-02-27 00:00:00",
+		"2041-02-26 00:00:00",
+	},
+}
+
+func Test_lastDay(t *testing.T) {
+	fmt.Println("Test_lastDay start")
+	base := time.Date(2039, 12, 01, 0, 0, 0, 0, time.Local)
+	for expression, wants := range lastDayCases {
+		fmt.Printf("Test %s\n", expression)
+		c := cron.ParseCronExpression(expression)
+		prev := base
+		for _, want := range wants {
+			next, _ := c.Next(prev)
+			actual := next.Format("2006-01-02 15:04:05")
+			if actual != want {
+				fmt.Printf("want: %s, actual: %s\n", want, actual)
+				t.Fatal(expression)
+			}
+			prev = next
+		}
+	}
+	for _, expression := range []string{"0 0 0 L-31 * ? *", "0 0 0 L-W * ? *", "0 0 0 WL * ? *", "0 0 0 L-0 * ? *", "0 0 0 LW-0 * ? *", "0 0 0 L-0W * ? *", "0 0 0 LW-23 * ? *", "0 0 0 L-31W * ? *"} {
+		if _, err := cron.TryParseCronExpression(expression); err == nil {
+			t.Fatal(expression)
+		}
+	}
+	if _, err := cron.TryParseCronExpression("0 0 0 L-31 * ? *"); !strings.Contains(err.Error(), "(allowed 1-30)") {
+		t.Fatal(err)
+	}
+	if _, err := cron.TryParseCronExpression("0 0 0 LW-30 * ? *"); !strings.Contains(err.Error(), "(allowed 1-22)") {
+		t.Fatal(err)
+	}
+	//the first day of a month with 23 weekdays
+	if next, ok := cron.ParseCronExpression("0 0 0 LW-22 * ? *").Next(baseTime); !ok || next.Format("2006-01-02") != "2020-07-01" {
+		t.Fatal(next, ok)
+	}
+	fmt.Println("Test_lastDay end")
+}
+
+var listCases = map[string]string{
+	"0 0 8-10,12,14-18/2 * * ?":  "0 0 8,9,10,12,14,16,18 * * ?",
+	"0 0,*/20,45 0 ? * 2-3,6 *":  "0 0,20,40,45 0 ? * 2,3,6 *",
+	"0 0 0 1,10-12,20/5 * ? *":   "0 0 0 1,10,11,12,20,25,30 * ? *",
+	"0 0 0 1 JAN-MAR,oct/2 ? *":  "0 0 0 1 1,2,3,10,12 ? *",
+	"0 0 0 1 1 ? 2040,2050-2052": "0 0 0 1 1 ? 2040,2050,2051,2052",
+}
+
+func Test_list(t *testing.T) {
+	fmt.Println("Test_list start")
+	for expression, expanded := range listCases {
+		fmt.Printf("Test %s\n", expression)
+		c := cron.ParseCronExpression(expression)
+		want := cron.ParseCronExpression(expanded)
+		prev := baseTime
+		for i := 0; i < 30; i++ {
+			actual, _ := c.Next(prev)
+			next, _ := want.Next(prev)
+			if !actual.Equal(next) {
+				fmt.Printf("want: %s, actual: %s\n", next, actual)
+				t.Fatal(expression)
+			}
+			prev = next
+		}
+	}
+	for _, expression := range []string{"0 0 8-10,25 * * ?", "0 0 8,,9 * * ?", "0 0 8,L * * ?", "0 0 0 1 1 ? 2040,3000"} {
+		if _, err := cron.TryParseCronExpression(expression); err == nil {
+			t.Fatal(expression)
+		}
+	}
+	fmt.Println("Test_list end")
+}
+
+func Test_timezone(t *testing.T) {
+	fmt.Println("Test_timezone start")
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	newYork, _ := time.LoadLocation("America/New_York")
+	base := time.Date(2037, 03, 06, 20, 36, 0, 0, time.UTC)
+	c := cron.ParseCronExpression("CRON_TZ=Asia/Shanghai 0 0 9 ? * MON-FRI")
+	want := time.Date(2037, 03, 9, 9, 0, 0, 0, shanghai)
+	if next, _ := c.Next(base); !next.Equal(want) || next.Location().String() != "Asia/Shanghai" {
+		t.Fatal(next, want)
+	}
+	if next := c.SetTime(base).MoveNext(); !next.Equal(want) {
+		t.Fatal(next, want)
+	}
+	parser := &cron.Parser{Location: newYork}
+	c, _ = parser.ParseCronExpression("0 0 9 ? * MON-FRI")
+	if next, _ := c.Next(base); !next.Equal(time.Date(2037, 03, 9, 9, 0, 0, 0, newYork)) {
+		t.Fatal(next)
+	}
+	cronJobs := cron.ParseCronData("TZ=Asia/Shanghai 0 0 9 * * ? echo sh\nCRON_TZ=America/New_York\n0 0 9 * * ? echo ny\n@daily echo daily")
+	wants := []time.Time{
+		time.Date(2037, 03, 7, 9, 0, 0, 0, shanghai),
+		time.Date(2037, 03, 7, 9, 0, 0, 0, newYork),
+		time.Date(2037, 03, 7, 0, 0, 0, 0, newYork),
+	}
+	for i, cj := range cronJobs {
+		if next, _ := cj.Next(base); !next.Equal(wants[i]) {
+			t.Fatal(cj.Desc, next, wants[i])
+		}
+	}
+	if _, err := cron.TryParseCronExpression("CRON_TZ=Mars/Olympus 0 0 9 * * ?"); err == nil {
+		t.Fatal("unknown zone should be rejected")
+	}
+	fmt.Println("Test_timezone end")
+}
+
+var dstCases = map[string][]string{
+	//2037-03-08 02:00 EST jumps to 03:00 EDT, skipped times fire once at the transition
+	"0 30 2 * * ?": []string{
+		"2037-03-07 02:30:00 EST",
+		"2037-03-08 03:00:00 EDT",
+		"2037-03-09 02:30:00 EDT",
+	},
+	"0 */20 2 * * ?": []string{
+		"2037-03-07 02:00:00 EST",
+		"2037-03-07 02:20:00 EST",
+		"2037-03-07 02:40:00 EST",
+		"2037-03-08 03:00:00 EDT",
+		"2037-03-09 02:00:00 EDT",
+	},
+	"0 30 3 * * ?": []string{
+		"2037-03-07 03:30:00 EST",
+		"2037-03-08 03:30:00 EDT",
+	},
+	//2037-11-01 02:00 EDT falls back to 01:00 EST, repeated times fire once
+	"0 30 1 1 11 ?": []string{
+		"2037-11-01 01:30:00 EDT",
+		"2038-11-01 01:30:00 EDT",
+	},
+	"0 */20 0-2 1 11 ?": []string{
+		"2037-11-01 00:00:00 EDT",
+		"2037-11-01 00:20:00 EDT",
+		"2037-11-01 00:40:00 EDT",
+		"2037-11-01 01:00:00 EDT",
+		"2037-11-01 01:20:00 EDT",
+		"2037-11-01 01:40:00 EDT",
+		"2037-11-01 02:00:00 EST",
+		"2037-11-01 02:20:00 EST",
+		"2037-11-01 02:40:00 EST",
+		"2038-11-01 00:00:00 EDT",
+	},
+}
+
+func Test_dst(t *testing.T) {
+	fmt.Println("Test_dst start")
+	newYork, _ := time.LoadLocation("America/New_York")
+	parser := &cron.Parser{Location: newYork}
+	base := time.Date(2037, 03, 07, 0, 0, 0, 0, newYork)
+	for expression, wants := range dstCases {
+		fmt.Printf("Test %s\n", expression)
+		c, _ := parser.ParseCronExpression(expression)
+		prev := base
+		fired := []time.Time{}
+		for _, want := range wants {
+			next, _ := c.Next(prev)
+			actual := next.Format("2006-01-02 15:04:05 MST")
+			if actual != want {
+				fmt.Printf("want: %s, actual: %s\n", want, actual)
+				t.Fatal(expression)
+			}
+			fired = append(fired, next)
+			prev = next
+		}
+		for i := len(fired) - 1; i > 0; i-- {
+			if p, _ := c.Prev(fired[i]); !p.Equal(fired[i-1]) {
+				fmt.Printf("want: %s, actual: %s\n", fired[i-1], p)
+				t.Fatal(expression)
+			}
+		}
+	}
+	c, _ := parser.ParseCronExpression("0 */20 * * * ?")
+	secondPass := time.Date(2037, 11, 1, 6, 10, 0, 0, time.UTC) //01:10 EST
+	if p, _ := c.Prev(secondPass); p.Format("15:04 MST") != "01:40 EDT" {
+		t.Fatal(p)
+	}
+	if n, _ := c.Next(secondPass); n.Format("15:04 MST") != "02:00 EST" {
+		t.Fatal(n)
+	}
+	fmt.Println("Test_dst end")
+}
+
+var describeCases = map[string][]string{
+	"0 */20 8-18 ? * 2-6 *":      []string{"every 20 minutes, between 08:00 and 18:59, Monday through Friday", "周一至周五，08:00至18:59之间，每20分钟"},
+	"0 15 10 ? * 6L 2030-2040":   []string{"at 10:15, on the last Friday of the month, in 2030 through 2040", "2030年至2040年，每月最后一个周五，10:15"},
+	"0 0 10,14,16 * * ? *":       []string{"at 10:00, 14:00 and 16:00", "每天，10:00、14:00、16:00"},
+	"0/20 * * * * ? *":           []string{"every 20 seconds", "每天，每20秒"},
+	"0 15 10 ? * 6#3 *":          []string{"at 10:15, on the third Friday of the month", "每月第3个周五，10:15"},
+	"0 0 0 L-3 * ? *":            []string{"at 00:00, 3 days before the last day of the month", "每月最后一天前3天，00:00"},
+	"0 0 9 15W * ? *":            []string{"at 09:00, on the weekday nearest day 15 of the month", "每月离15号最近的工作日，09:00"},
+	"0 0-5 14 * * ? *":           []string{"every minute from minute 0 through 5, between 14:00 and 14:59", "每天，14:00至14:59之间，第0分至第5分每分钟"},
+	"0 0 12 1,15 JAN,JUL ?":      []string{"at 12:00, on days 1 and 15 of the month, in January and July", "1月、7月，每月1、15号，12:00"},
+	"0 30 9 ? * MON,WED,FRI":     []string{"at 09:30, on Monday, Wednesday and Friday", "每周一、周三、周五，09:30"},
+	"30 0 10 LW * ?":             []string{"at 10:00:30, on the last weekday of the month", "每月最后一个工作日，10:00:30"},
+	"@reboot":                    []string{"at startup", "程序启动时"},
+	"0 0 0 1 * ? 2020/5":         []string{"at 00:00, on day 1 of the month, every 5 years starting in 2020", "2020年起每5年，每月1号，00:00"},
+	"0 0 0 1 3/2 ? 2020-2040/10": []string{"at 00:00, on day 1 of the month, every 2 months starting in March, every 10 years from 2020 through 2040", "2020年至2040年每10年，3月起每2个月，每月1号，00:00"},
+	"0 0 0 1 */3 ? *":            []string{"at 00:00, on day 1 of the month, every 3 months", "每3个月，每月1号，00:00"},
+}
+
+func Test_describe(t *testing.T) {
+	fmt.Println("Test_describe start")
+	for expression, wants := range describeCases {
+		fmt.Printf("Test %s\n", expression)
+		c := cron.ParseCronExpression(expression)
+		if actual := c.Describe(); actual != wants[0] {
+			fmt.Printf("want: %s, actual: %s\n", wants[0], actual)
+			t.Fatal(expression)
+		}
+		if actual := c.DescribeIn("zh"); actual != wants[1] {
+			fmt.Printf("want: %s, actual: %s\n", wants[1], actual)
+			t.Fatal(expression)
+		}
+	}
+	c, _ := (&cron.Parser{Mode: cron.UnixMode}).ParseCronExpression("0 12 1,15 * 1-5")
+	if actual := c.Describe(); actual != "at 12:00, on days 1 and 15 of the month or Monday through Friday" {
+		t.Fatal(actual)
+	}
+	fmt.Println("Test_describe end")
+}
+
+var stringCases = map[string]string{
+	"0/1 * * * * ?":                     "* * * * * ? *",
+	"0 0 8-10,12,14-18/2 * * ?":         "0 0 8-10,12-18/2 * * ? *",
+	"0 0 12 1,15 JAN,JUL ?":             "0 0 12 1,15 1,7 ? *",
+	"0 30 9 ? * MON-FRI":                "0 30 9 ? * 2-6 *",
+	"0 15 10 ? * 6L 2030-2040":          "0 15 10 ? * 6L 2030-2040",
+	"0 0 0 L-3 * ? *":                   "0 0 0 L-3 * ? *",
+	"5/15 0 0 15W * ? *":                "5/15 0 0 15W * ? *",
+	"0 0 0 1,2,3,4 * ? *":               "0 0 0 1-4 * ? *",
+	"CRON_TZ=Asia/Shanghai 0 0 9 * * ?": "CRON_TZ=Asia/Shanghai 0 0 9 * * ? *",
+	"@daily":                            "0 0 0 * * ? *",
+	"@reboot":                           "@reboot",
+}
+
+var unixStringCases = map[string]string{
+	"*/5 * * * *":      "*/5 * * * *",
+	"0 12 1,15 * 1-5":  "0 12 1,15 * 1-5",
+	"0 0 */10 * 1,3,5": "0 0 */10 * 1-5/2",
+	"0 0 1 * 7":        "0 0 1 * 0",
+	"0 0 * * 0-6":      "0 0 * * *",
+	"0 0 1 * 0-6":      "0 0 1 * 0-6",
+	"0 0 */20 * 1":     "0 0 */20 * 1",
+	"@daily":           "0 0 * * *",
+	"@hourly":          "0 * * * *",
+	"@weekly":          "0 0 * * 0",
+}
+
+func Test_string(t *testing.T) {
+	fmt.Println("Test_string start")
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local)
+	check := func(p *cron.Parser, expression, want string) {
+		fmt.Printf("Test %s\n", expression)
+		c, err := p.ParseCronExpression(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := c.String(); actual != want {
+			fmt.Printf("want: %s, actual: %s\n", want, actual)
+			t.Fatal(expression)
+		}
+		again, err := p.ParseCronExpression(c.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.String() != want {
+			t.Fatal(again.String())
+		}
+		for i, t1, t2 := 0, base, base; i < 20 && !c.Reboot; i++ {
+			var ok1, ok2 bool
+			t1, ok1 = c.Next(t1)
+			t2, ok2 = again.Next(t2)
+			if ok1 != ok2 || !t1.Equal(t2) {
+				fmt.Printf("want: %v, actual: %v\n", t1, t2)
+				t.Fatal(expression)
+			}
+		}
+	}
+	for expression, want := range stringCases {
+		check(&cron.Parser{}, expression, want)
+	}
+	for expression, want := range unixStringCases {
+		check(&cron.Parser{Mode: cron.UnixMode}, expression, want)
+	}
+	e, _ := cron.TryParseEvery("@every 1m30s from 2030-01-01T00:00:00Z")
+	if e.String() != "@every 1m30s from 2030-01-01T00:00:00Z" {
+		t.Fatal(e.String())
+	}
+	fmt.Println("Test_string end")
+}
+
+var benchmarkExpressions = []string{"0/20 * * * * ? *", "0 0 8-10,12,14-18/2 * * ?", "0 15 10 ? * 6L *", "0 0 0 LW * ? *", "0 30 9 ? * MON-FRI"}
+
+func BenchmarkNext(b *testing.B) {
+	b.ReportAllocs()
+	for _, expression := range benchmarkExpressions {
+		c, _ := (&cron.Parser{Location: time.UTC}).ParseCronExpression(expression)
+		b.Run(expression, func(b *testing.B) {
+			b.ReportAllocs()
+			t := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < b.N; i++ {
+				next, _ := c.Next(t)
+				if next.Year() > 2100 {
+					next = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+				}
+				t = next
+			}
+		})
+	}
+}
+
+func BenchmarkPrev(b *testing.B) {
+	for _, expression := range benchmarkExpressions {
+		c, _ := (&cron.Parser{Location: time.UTC}).ParseCronExpression(expression)
+		b.Run(expression, func(b *testing.B) {
+			b.ReportAllocs()
+			t := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < b.N; i++ {
+				prev, _ := c.Prev(t)
+				if prev.Year() < 2030 {
+					prev = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+				}
+				t = prev
+			}
+		})
+	}
+}
+
+func BenchmarkSchedule(b *testing.B) {
+	b.ReportAllocs()
+	jobs := []*cron.CronJob{}
+	for i := 0; i < 1000; i++ {
+		cj := cron.ParseCronJob(fmt.Sprintf("%d %d * * * ? echo %d", i%60, i/60%60, i))
+		cj.MoveNext()
+		jobs = append(jobs, cj)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		schedule := cron.Schedule{}
+		for _, cj := range jobs {
+			heap.Push(&schedule, cj)
+		}
+		for schedule.Len() > 0 {
+			heap.Pop(&schedule)
+		}
+	}
+}
+
+func Test_nextAllocs(t *testing.T) {
+	fmt.Println("Test_nextAllocs start")
+	for _, expression := range benchmarkExpressions {
+		c, _ := (&cron.Parser{Location: time.UTC}).ParseCronExpression(expression)
+		from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		allocs := testing.AllocsPerRun(100, func() {
+			c.Next(from)
+			c.Prev(from)
+		})
+		if allocs != 0 {
+			fmt.Printf("want: 0 allocs, actual: %v\n", allocs)
+			t.Fatal(expression)
+		}
+	}
+	fmt.Println("Test_nextAllocs end")
+}
+
+func Test_iterator(t *testing.T) {
+	fmt.Println("Test_iterator start")
+	c := cron.ParseCronExpression("0 30 9 ? * MON-FRI 2030")
+	monday := time.Date(2030, 3, 4, 0, 0, 0, 0, time.Local)
+	times := c.Between(monday, monday.AddDate(0, 0, 7))
+	if len(times) != 5 || times[0] != monday.Add(9*time.Hour+30*time.Minute) || times[4].Weekday() != time.Friday {
+		t.Fatal(times)
+	}
+	if times := c.Between(times[0], times[1]); len(times) != 1 {
+		t.Fatal(times)
+	}
+	times = c.NextN(monday, 10)
+	if len(times) != 10 || times[9].Day() != 15 {
+		t.Fatal(times)
+	}
+	//the expression ends with 2030
+	if times := c.NextN(time.Date(2030, 12, 30, 0, 0, 0, 0, time.Local), 10); len(times) != 2 {
+		t.Fatal(times)
+	}
+	c.IsEnd = true
+	if times := c.NextN(monday, 10); len(times) != 0 {
+		t.Fatal(times)
+	}
+	every := cron.ParseCronExpression("* * * * * ? *")
+	if times := every.Between(monday, monday.AddDate(1, 0, 0)); len(times) != cron.MaxIterations {
+		t.Fatal(len(times))
+	}
+	n := 0
+	for it := cron.Iterate(cron.Every(time.Minute, monday), monday, monday.Add(time.Hour)); it.Next(); n++ {
+	}
+	if n != 59 {
+		t.Fatal(n)
+	}
+	fmt.Println("Test_iterator end")
+}
+
+func Test_yearWindow(t *testing.T) {
+	fmt.Println("Test_yearWindow start")
+	c, err := cron.TryParseCronExpression("0 0 0 1 1 ? 2019-2030")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev, ok := c.Prev(time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local)); !ok || prev.Year() != 2025 {
+		t.Fatal(prev)
+	}
+	c.SetTime(time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local))
+	c.MoveNext()
+	if !c.IsEnd {
+		t.Fatal(c.ToTime())
+	}
+	if _, err := cron.TryParseCronExpression("0 0 0 1 1 ? 1969"); err == nil {
+		t.Fatal("1969")
+	}
+	c = cron.ParseCronExpression("0 0 0 1 1 ? 1970/100")
+	if times := c.NextN(time.Time{}, 5); len(times) != 3 || times[2].Year() != 2170 {
+		t.Fatal(times)
+	}
+	c = cron.ParseCronExpression("0 0 0 * * ? *")
+	if next, ok := c.Next(time.Date(2199, 12, 31, 0, 0, 0, 0, time.Local)); ok {
+		t.Fatal(next)
+	}
+	fmt.Println("Test_yearWindow end")
+}
+
+func Test_hash(t *testing.T) {
+	fmt.Println("Test_hash start")
+	a, _ := (&cron.Parser{Key: "backup"}).ParseCronExpression("H H * * * ?")
+	b, _ := (&cron.Parser{Key: "backup"}).ParseCronExpression("H H * * * ?")
+	if a.String() != b.String() {
+		t.Fatal(a.String(), b.String())
+	}
+	spread := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		c, err := (&cron.Parser{Key: fmt.Sprintf("job%d", i)}).ParseCronExpression("0 H(0-29) H/6 H * ? *")
+		if err != nil {
+			t.Fatal(err)
+		}
+		spread[c.String()] = true
+		for _, next := range c.NextN(time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local), 8) {
+			if next.Minute() > 29 || next.Hour()%6 != c.NextN(time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local), 1)[0].Hour()%6 || next.Day() > 28 {
+				t.Fatal(c.String(), next)
+			}
+		}
+	}
+	if len(spread) < 10 {
+		t.Fatal(spread)
+	}
+	c, _ := (&cron.Parser{Key: "report"}).ParseCronExpression("0 H(0-29)/10 H,12 ? * MON-FRI")
+	//the hash is stable, so the spread survives restarts
+	if c.String() != "0 7-27/10 11,12 ? * 2-6 *" {
+		t.Fatal(c.String())
+	}
+	if _, err := cron.TryParseCronExpression("0 H(50-70) * * * ?"); err == nil {
+		t.Fatal("H(50-70)")
+	}
+	for _, expression := range []string{"0 1H * * * ?", "0 H2 * * * ?", "0 H-5 * * * ?", "0 0 0 1 1 ? H", "0 0 0 1 1 ? H/5"} {
+		if _, err := cron.TryParseCronExpression(expression); err == nil {
+			t.Fatal(expression)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		c, err := (&cron.Parser{Key: fmt.Sprintf("job%d", i)}).ParseCronExpression("0 0 0 1 1 ? H(2030-2040)")
+		if next, ok := c.Next(baseTime); err != nil || !ok || next.Year() < 2030 || next.Year() > 2040 {
+			t.Fatal(err, next)
+		}
+	}
+	cj := cron.ParseCronJob("H H * * * ? id=backup echo backup")
+	if cj.ID != "backup" || cj.Desc != "echo backup" || cj.Expression.(*cron.CronExpression).String() != a.String() {
+		t.Fatal(cj.ID, cj.Desc)
+	}
+	x := cron.ParseCronJob("0 H * * * ? echo x")
+	y := cron.ParseCronJob("0 H * * * ? echo x")
+	if x.Expression.(*cron.CronExpression).String() != y.Expression.(*cron.CronExpression).String() {
+		t.Fatal("same command hashed differently")
+	}
+	if _, err := cron.TryParseCronJob("0 0 * * * ? id= echo x"); err == nil {
+		t.Fatal("empty id")
+	}
+	fmt.Println("Test_hash end")
+}
+
+func Test_jitter(t *testing.T) {
+	fmt.Println("Test_jitter start")
+	cj := cron.ParseCronJob("0 */1 12-22 * * ? id=tianapi jitter=30s curl http://example.com")
+	if cj.Jitter != 30*time.Second || cj.ID != "tianapi" || cj.Desc != "curl http://example.com" {
+		t.Fatal(cj.Jitter, cj.ID, cj.Desc)
+	}
+	for i := 0; i < 100; i++ {
+		if delay := cj.JitterDelay(); delay < 0 || delay > 30*time.Second {
+			t.Fatal(delay)
+		}
+	}
+	if delay := cron.ParseCronJob("0 * * * * ? echo x").JitterDelay(); delay != 0 {
+		t.Fatal(delay)
+	}
+	if _, err := cron.TryParseCronJob("0 * * * * ? jitter=-1s echo x"); err == nil {
+		t.Fatal("negative jitter")
+	}
+	fmt.Println("Test_jitter end")
+}
+
+var holidays = `# public holidays
+2030-01-01
+2030-10-01..2030-10-07
+`
+
+var freeze = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nSUMMARY:New Year\r\nDTSTART;VALUE=DATE:20300101\r\nDTEND;VALUE=DATE:20300103\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Change freeze\r\nDTSTART:20300115T090000Z\r\nDTEND:20300116T0\r\n 90000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+var recurringHolidays = "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Labour Day\r\nDTSTART;VALUE=DATE:20300501\r\nDTEND;VALUE=DATE:20300502\r\nRRULE:FREQ=YEARLY\r\nEXDATE;VALUE=DATE:20310501\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nSUMMARY:Weekly maintenance\r\nDTSTART;TZID=UTC:20300107T090000\r\nDTEND;TZID=UTC:20300107T110000\r\nRRULE:FREQ=WEEKLY;BYDAY=MO\r\nEXDATE;TZID=UTC:20300114T090000\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func Test_calendar(t *testing.T) {
+	fmt.Println("Test_calendar start")
+	runs := func(cj *cron.CronJob, from time.Time, n int) string {
+		days := []string{}
+		for t := from; len(days) < n; {
+			var ok bool
+			if t, ok = cj.NextAfter(t); !ok {
+				break
+			}
+			days = append(days, t.Format("01-02T15"))
+		}
+		return strings.Join(days, ",")
+	}
+	hc, err := cron.TryParseCalendarData(holidays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cj := cron.ParseCronJob("CRON_TZ=UTC 0 0 9 * * ? echo report")
+	cj.Calendars = []cron.Calendar{hc}
+	if actual := runs(cj, time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC), 3); actual != "12-31T09,01-02T09,01-03T09" {
+		t.Fatal(actual)
+	}
+	if actual := runs(cj, time.Date(2030, 9, 30, 10, 0, 0, 0, time.UTC), 2); actual != "10-08T09,10-09T09" {
+		t.Fatal(actual)
+	}
+	ics, err := cron.TryParseCalendarData(freeze)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cj = cron.ParseCronJob("CRON_TZ=UTC 0 0 */6 * * ? echo sync")
+	cj.Calendars = []cron.Calendar{ics}
+	if actual := runs(cj, time.Date(2029, 12, 31, 20, 0, 0, 0, time.UTC), 2); actual != "01-03T00,01-03T06" {
+		t.Fatal(actual)
+	}
+	if actual := runs(cj, time.Date(2030, 1, 15, 0, 0, 0, 0, time.UTC), 3); actual != "01-15T06,01-16T12,01-16T18" {
+		t.Fatal(actual)
+	}
+	cj.Calendars = []cron.Calendar{&cron.CronCalendar{Expression: cron.ParseCronExpression("CRON_TZ=UTC * * 0-7,18-23 ? * *")}}
+	if actual := runs(cj, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), 3); actual != "01-01T12,01-02T12,01-03T12" {
+		t.Fatal(actual)
+	}
+	if _, err := cron.TryParseCalendarData("2030-13-01"); err == nil {
+		t.Fatal("2030-13-01")
+	}
+	recurring, err := cron.TryParseCalendarData(recurringHolidays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cj = cron.ParseCronJob("0 0 9 * * ? echo report")
+	cj.Calendars = []cron.Calendar{recurring}
+	for year, want := range map[int]string{2030: "04-30T09,05-02T09", 2031: "04-30T09,05-01T09", 2032: "04-30T09,05-02T09"} {
+		if actual := runs(cj, time.Date(year, 4, 30, 0, 0, 0, 0, time.Local), 2); actual != want {
+			t.Fatal(year, actual)
+		}
+	}
+	cj = cron.ParseCronJob("CRON_TZ=UTC 0 0 */2 * * ? echo sync")
+	cj.Calendars = []cron.Calendar{recurring}
+	if actual := runs(cj, time.Date(2030, 1, 7, 6, 0, 0, 0, time.UTC), 3); actual != "01-07T08,01-07T12,01-07T14" {
+		t.Fatal(actual)
+	}
+	if actual := runs(cj, time.Date(2030, 1, 14, 6, 0, 0, 0, time.UTC), 3); actual != "01-14T08,01-14T10,01-14T12" {
+		t.Fatal(actual)
+	}
+	for _, event := range []string{"RRULE:FREQ=YEARLY;BYWEEKNO=1", "RDATE;VALUE=DATE:20300601"} {
+		if _, err := cron.TryParseCalendarData("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20300501\n" + event + "\nEND:VEVENT\nEND:VCALENDAR"); err == nil {
+			t.Fatal(event)
+		}
+	}
+	file, _ := ioutil.TempFile("", "holidays")
+	defer os.Remove(file.Name())
+	file.WriteString(holidays)
+	file.Close()
+	cj = cron.ParseCronJob("CRON_TZ=UTC 0 0 9 * * ? calendar=" + file.Name() + " echo report")
+	if len(cj.Calendars) != 1 || cj.Desc != "echo report" {
+		t.Fatal(cj.Calendars, cj.Desc)
+	}
+	if _, err := cron.TryParseCronJob("0 0 9 * * ? calendar=/no/such/file echo report"); err == nil {
+		t.Fatal("missing calendar")
+	}
+	fmt.Println("Test_calendar end")
+}
+
+var rruleCases = map[string][]string{
+	"RRULE:FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1":                   []string{"2030-01-29T09:00", "2030-02-26T09:00", "2030-03-26T09:00"},
+	"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=4":             []string{"2030-01-04T09:00", "2030-01-14T09:00", "2030-01-18T09:00", "2030-01-28T09:00"},
+	"RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1":                    []string{"2030-02-28T09:00", "2031-02-28T09:00", "2032-02-29T09:00"},
+	"RRULE:FREQ=DAILY;BYHOUR=9,17;UNTIL=20300103T120000Z":          []string{"2030-01-01T09:00", "2030-01-01T17:00", "2030-01-02T09:00", "2030-01-02T17:00", "2030-01-03T09:00"},
+	"RRULE:FREQ=HOURLY;INTERVAL=6;BYDAY=SA":                        []string{"2030-01-05T03:00", "2030-01-05T09:00", "2030-01-05T15:00", "2030-01-05T21:00", "2030-01-12T03:00"},
+	"RRULE:FREQ=MINUTELY;INTERVAL=15;BYHOUR=10":                    []string{"2030-01-01T10:00", "2030-01-01T10:15", "2030-01-01T10:30", "2030-01-01T10:45", "2030-01-02T10:00"},
+	"RRULE:FREQ=YEARLY;BYDAY=20MO":                                 []string{"2030-05-20T09:00", "2031-05-19T09:00"},
+	"RRULE:FREQ=YEARLY;BYMONTH=1,7;BYDAY=1MO;BYHOUR=8;BYMINUTE=30": []string{"2030-01-07T08:30", "2030-07-01T08:30", "2031-01-06T08:30"},
+	"RRULE:FREQ=DAILY;INTERVAL=3;COUNT=3":                          []string{"2030-01-01T09:00", "2030-01-04T09:00", "2030-01-07T09:00"},
+	"RRULE:FREQ=MONTHLY;BYMONTHDAY=31;COUNT=2":                     []string{"2030-01-31T09:00", "2030-03-31T09:00"},
+}
+
+func Test_rrule(t *testing.T) {
+	fmt.Println("Test_rrule start")
+	parser := &cron.Parser{Location: time.UTC}
+	for rule, wants := range rruleCases {
+		fmt.Printf("Test %s\n", rule)
+		r, err := parser.ParseRRule("DTSTART:20300101T090000 " + rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, want := range wants {
+			prev := next
+			var ok bool
+			if next, ok = r.Next(next); !ok || next.Format("2006-01-02T15:04") != want {
+				fmt.Printf("want: %s, actual: %v\n", want, next)
+				t.Fatal(rule)
+			}
+			if back, ok := r.Prev(next); prev.Year() > 2029 && (!ok || !back.Equal(prev)) {
+				fmt.Printf("want: %v, actual: %v\n", prev, back)
+				t.Fatal(rule)
+			}
+		}
+		if strings.Contains(rule, "COUNT") || strings.Contains(rule, "UNTIL") {
+			if next, ok := r.Next(next); ok {
+				t.Fatal(rule, next)
+			}
+		}
+	}
+	cj := cron.ParseCronJob("CRON_TZ=Asia/Shanghai DTSTART:20300101T090000 RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR echo standup")
+	if next, _ := cj.Next(time.Date(2030, 1, 4, 12, 0, 0, 0, time.UTC)); next.Format(time.RFC3339) != "2030-01-07T09:00:00+08:00" || cj.Desc != "echo standup" {
+		t.Fatal(next, cj.Desc)
+	}
+	if s := cj.Expression.(*cron.RRuleExpression).String(); s != "DTSTART;TZID=Asia/Shanghai:20300101T090000 RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR" {
+		t.Fatal(s)
+	}
+	//the rule follows the TZID of DTSTART across New York's daylight saving change on 2030-03-10
+	line := "DTSTART;TZID=America/New_York:20300101T090000 RRULE:FREQ=DAILY"
+	ny, err := cron.TryParseRRule(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for from, want := range map[time.Time]string{
+		time.Date(2030, 3, 8, 0, 0, 0, 0, time.UTC):  "2030-03-08T14:00:00Z",
+		time.Date(2030, 3, 11, 0, 0, 0, 0, time.UTC): "2030-03-11T13:00:00Z",
+	} {
+		if next, _ := ny.Next(from); next.UTC().Format(time.RFC3339) != want {
+			t.Fatal(next.UTC(), want)
+		}
+	}
+	if ny.String() != line {
+		t.Fatal(ny.String())
+	}
+	//a CRON_TZ prefix still decides the zone
+	shanghai, _ := cron.TryParseExpression("CRON_TZ=Asia/Shanghai " + line)
+	if s := shanghai.(*cron.RRuleExpression).String(); s != "DTSTART;TZID=Asia/Shanghai:20300101T220000 RRULE:FREQ=DAILY" {
+		t.Fatal(s)
+	}
+	//a large COUNT does not make every call enumerate the occurrences from DTSTART
+	start := time.Now()
+	huge, _ := parser.ParseRRule("DTSTART:20300101T000000 RRULE:FREQ=SECONDLY;COUNT=2000000000")
+	if next, ok := huge.Next(time.Date(2060, 1, 1, 0, 0, 0, 0, time.UTC)); !ok || next.Format(time.RFC3339) != "2060-01-01T00:00:01Z" {
+		t.Fatal(next)
+	}
+	last := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Add(1999999999 * time.Second)
+	if prev, ok := huge.Prev(time.Date(2150, 1, 1, 0, 0, 0, 0, time.UTC)); !ok || !prev.Equal(last) {
+		t.Fatal(prev)
+	}
+	if next, ok := huge.Next(last.Add(-time.Second)); !ok || !next.Equal(last) {
+		t.Fatal(next)
+	}
+	if next, ok := huge.Next(last); ok {
+		t.Fatal(next)
+	}
+	weekdays, _ := parser.ParseRRule("DTSTART:20300101T000000 RRULE:FREQ=MINUTELY;BYDAY=MO,TU,WE,TH,FR;COUNT=2000000000")
+	var next time.Time
+	for day := 0; day < 100; day++ {
+		next, _ = weekdays.Next(time.Date(2030, 1, 1+day, 12, 0, 30, 0, time.UTC))
+	}
+	if next.Format(time.RFC3339) != "2030-04-10T12:01:00Z" || time.Since(start) > 2*time.Second {
+		t.Fatal(next, time.Since(start))
+	}
+	if prev, _ := weekdays.Prev(time.Date(2030, 1, 6, 0, 0, 0, 0, time.UTC)); prev.Format(time.RFC3339) != "2030-01-04T23:59:00Z" {
+		t.Fatal(prev)
+	}
+	jobs, err := cron.TryParseCronData("RRULE:FREQ=HOURLY echo hourly\n0 0 * * * ? echo cron")
+	if err != nil || len(jobs) != 2 {
+		t.Fatal(err)
+	}
+	for _, rule := range []string{"RRULE:FREQ=DAILY;BYWEEKNO=1", "RRULE:BYHOUR=9", "RRULE:FREQ=DAILY;COUNT=2;UNTIL=20300101", "RRULE:FREQ=DAILY;BYMONTHDAY=0"} {
+		if _, err := cron.TryParseRRule(rule); err == nil {
+			t.Fatal(rule)
+		}
+	}
+	fmt.Println("Test_rrule end")
+}
+
+// exited waits up to 2 seconds for the process to exit, zombies count as exited.
+func exited(pid string) bool {
+	for i := 0; i < 20; i++ {
+		stat, err := ioutil.ReadFile("/proc/" + pid + "/stat")
+		if exec.Command("kill", "-0", pid).Run() != nil || (err == nil && strings.Contains(string(stat), ") Z ")) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func Test_timeout(t *testing.T) {
+	fmt.Println("Test_timeout start")
+	defer func(grace time.Duration) { cron.TimeoutGrace = grace }(cron.TimeoutGrace)
+	cron.TimeoutGrace = 300 * time.Millisecond
+	pidFile, _ := ioutil.TempFile("", "pid")
+	pidFile.Close()
+	defer os.Remove(pidFile.Name())
+	for _, script := range []string{"sleep 30 & echo $! > " + pidFile.Name() + "; wait", "trap '' TERM; sleep 30 & echo $! > " + pidFile.Name() + "; wait"} {
+		c := make(chan cron.JobResult, 1)
+		start := time.Now()
+		go (&cron.ShellAction{Script: script, Timeout: 200 * time.Millisecond}).Run(context.Background(), c)
+		result := <-c
+		if result.Code != cron.TimeoutCode || time.Since(start) > 5*time.Second {
+			t.Fatal(script, result, time.Since(start))
+		}
+		pid, _ := ioutil.ReadFile(pidFile.Name())
+		if !exited(strings.TrimSpace(string(pid))) {
+			t.Fatal("child still running", script)
+		}
+	}
+	c := make(chan cron.JobResult, 1)
+	go (&cron.ShellAction{Script: "echo done", Timeout: 10 * time.Second}).Run(context.Background(), c)
+	if result := <-c; result.Code != 0 {
+		t.Fatal(result)
+	}
+	cj := cron.ParseCronJob("0 * * * * ? timeout=10m ping www.baidu.com")
+	if cj.Timeout != 10*time.Minute || cj.Desc != "ping www.baidu.com" {
+		t.Fatal(cj.Timeout, cj.Desc)
+	}
+	fmt.Println("Test_timeout end")
+}
+
+func Test_concurrency(t *testing.T) {
+	fmt.Println("Test_concurrency start")
+	started := make(chan bool, 10)
+	release := make(chan bool)
+	block := func(ctx context.Context, c chan cron.JobResult) {
+		started <- true
+		select {
+		case <-release:
+			c <- cron.JobResult{Code: 0, Msg: "released"}
+		case <-ctx.Done():
+			c <- cron.JobResult{Code: cron.CanceledCode, Msg: "canceled"}
+		}
+	}
+	job := &cron.Job{Desc: "forbid", ContextAction: block, Concurrency: cron.Forbid}
+	done := make(chan bool)
+	go func() {
+		job.Run()
+		done <- true
+	}()
+	<-started
+	job.Run()
+	if job.Skipped() != 1 {
+		t.Fatal(job.Skipped())
+	}
+	release <- true
+	<-done
+	job = &cron.Job{Desc: "replace", ContextAction: block, Concurrency: cron.Replace}
+	go job.Run()
+	<-started
+	go func() {
+		job.Run()
+		done <- true
+	}()
+	<-started
+	if job.Replaced() != 1 || job.LastRunResult != cron.CanceledCode {
+		t.Fatal(job.Replaced(), job.LastRunResult)
+	}
+	release <- true
+	<-done
+	job = &cron.Job{Desc: "allow", ContextAction: block}
+	for i := 0; i < 2; i++ {
+		go func() {
+			job.Run()
+			done <- true
+		}()
+	}
+	<-started
+	<-started
+	release <- true
+	release <- true
+	<-done
+	<-done
+	if job.Skipped() != 0 || job.Replaced() != 0 || job.Status != cron.Wait {
+		t.Fatal(job.Skipped(), job.Replaced(), job.Status)
+	}
+	if cj := cron.ParseCronJob("0 * * * * ? concurrency=forbid echo x"); cj.Concurrency != cron.Forbid {
+		t.Fatal(cj.Concurrency)
+	}
+	if _, err := cron.TryParseCronJob("0 * * * * ? concurrency=never echo x"); err == nil {
+		t.Fatal("concurrency=never")
+	}
+	fmt.Println("Test_concurrency end")
+}
+
+func Test_retries(t *testing.T) {
+	fmt.Println("Test_retries start")
+	calls := 0
+	attempts := []string{}
+	job := &cron.Job{Desc: "flaky", Retries: 3, Backoff: 20 * time.Millisecond,
+		Action: func(c chan cron.JobResult) {
+			calls++
+			if calls < 3 {
+				c <- cron.JobResult{Code: 1, Msg: "failed"}
+			} else {
+				c <- cron.JobResult{Code: 0, Msg: "ok"}
+			}
+		},
+		OnResult: func(result cron.JobResult) {
+			attempts = append(attempts, fmt.Sprintf("%d:%d", result.Attempt, result.Code))
+		},
+	}
+	start := time.Now()
+	job.Run()
+	//20ms then 40ms, each with up to 50% jitter
+	if elapsed := time.Since(start); strings.Join(attempts, ",") != "1:1,2:1,3:0" || job.LastRunResult != 0 || elapsed < 60*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatal(attempts, job.LastRunResult, elapsed)
+	}
+	calls, attempts = -10, []string{}
+	job.Retries = 1
+	job.Run()
+	if strings.Join(attempts, ",") != "1:1,2:1" || job.LastRunResult != 1 {
+		t.Fatal(attempts, job.LastRunResult)
+	}
+	cj := cron.ParseCronJob("0 * * * * ? retries=3 backoff=30s curl http://example.com")
+	if cj.Retries != 3 || cj.Backoff != 30*time.Second || cj.Desc != "curl http://example.com" {
+		t.Fatal(cj.Retries, cj.Backoff, cj.Desc)
+	}
+	fmt.Println("Test_retries end")
+}
+
+func Test_output(t *testing.T) {
+	fmt.Println("Test_output start")
+	logFile, _ := ioutil.TempFile("", "log")
+	logFile.WriteString("previous\n")
+	logFile.Close()
+	defer os.Remove(logFile.Name())
+	c := make(chan cron.JobResult, 1)
+	go (&cron.ShellAction{Script: "seq 1 1000; echo failed >&2", OutputLimit: 10, LogFile: logFile.Name()}).Run(context.Background(), c)
+	result := <-c
+	if result.Code != 0 || result.Stdout != "\n999\n1000\n" || !result.StdoutTruncated || result.Stderr != "failed\n" || result.StderrTruncated {
+		t.Fatalf("%+v", result)
+	}
+	content, _ := ioutil.ReadFile(logFile.Name())
+	if !strings.HasPrefix(string(content), "previous\n1\n2\n") || !strings.Contains(string(content), "1000\n") || !strings.Contains(string(content), "failed\n") {
+		t.Fatal(string(content))
+	}
+	go (&cron.ShellAction{Script: "echo short"}).Run(context.Background(), c)
+	if result := <-c; result.Stdout != "short\n" || result.StdoutTruncated {
+		t.Fatalf("%+v", result)
+	}
+	go cron.MakeAction("echo made")(c)
+	if result := <-c; result.Code != 0 || result.Stdout != "made\n" {
+		t.Fatalf("%+v", result)
+	}
+	cj := cron.ParseCronJob("0 * * * * ? output=16KB log=/tmp/backup.log backup.sh")
+	if cj.OutputLimit != 16*1024 || cj.LogFile != "/tmp/backup.log" || cj.Desc != "backup.sh" {
+		t.Fatal(cj.OutputLimit, cj.LogFile, cj.Desc)
+	}
+	if _, err := cron.TryParseCronJob("0 * * * * ? output=lots backup.sh"); err == nil {
+		t.Fatal("invalid output size accepted")
+	}
+	fmt.Println("Test_output end")
+}
+
+func Test_environment(t *testing.T) {
+	fmt.Println("Test_environment start")
+	dir, _ := ioutil.TempDir("", "cwd")
+	defer os.RemoveAll(dir)
+	cronJobs, err := cron.TryParseCronData(`
+SHELL=/bin/sh
+GREETING = "hello world "
+0 * * * * ? echo "$GREETING|$(pwd)|$0"
+PATH=/usr/bin:/bin
+0 * * * * ? cwd=` + dir + ` shell=bash env=GREETING=hi env=LANG=C echo "$GREETING|$(pwd)|$0|$LANG|$PATH"
+`)
+	if err != nil || len(cronJobs) != 2 {
+		t.Fatal(cronJobs, err)
+	}
+	if strings.Join(cronJobs[0].Env, ",") != "SHELL=/bin/sh,GREETING=hello world " || cronJobs[0].Shell != "/bin/sh" {
+		t.Fatal(cronJobs[0].Env, cronJobs[0].Shell)
+	}
+	if strings.Join(cronJobs[1].Env, ",") != "SHELL=/bin/sh,PATH=/usr/bin:/bin,GREETING=hi,LANG=C" || cronJobs[1].Shell != "bash" || cronJobs[1].Dir != dir || cronJobs[1].Desc != `echo "$GREETING|$(pwd)|$0|$LANG|$PATH"` {
+		t.Fatal(cronJobs[1].Env, cronJobs[1].Shell, cronJobs[1].Dir, cronJobs[1].Desc)
+	}
+	cwd, _ := os.Getwd()
+	expected := []string{"hello world |" + cwd + "|/bin/sh\n", "hi|" + dir + "|bash|C|/usr/bin:/bin\n"}
+	for i, cj := range cronJobs {
+		c := make(chan cron.JobResult, 1)
+		go cj.Action(c)
+		if result := <-c; result.Code != 0 || result.Stdout != expected[i] {
+			t.Fatalf("%+v", result)
+		}
+	}
+	c := make(chan cron.JobResult, 1)
+	go (&cron.ShellAction{Script: "pwd", Dir: dir + "/missing"}).Run(context.Background(), c)
+	if result := <-c; result.Code != cron.ErrorCode {
+		t.Fatalf("%+v", result)
+	}
+	if _, err := cron.TryParseCronJob("0 * * * * ? env=oops echo"); err == nil {
+		t.Fatal("invalid env accepted")
+	}
+	fmt.Println("Test_environment end")
+}
+
+func Test_legacy(t *testing.T) {
+	fmt.Println("Test_legacy start")
+	c := cron.ParseCronExpression("0 30 9 ? * 2 *").SetTime(time.Date(2030, 3, 6, 20, 36, 0, 0, time.Local))
+	if c.CheckDay() || c.CheckHour() || !c.CheckYear() {
+		t.Fatal(c.CheckDay(), c.CheckHour(), c.CheckYear())
+	}
+	if next := c.MoveNextDay(); next.Format("2006-01-02 15:04:05") != "2030-03-11 09:30:00" || !c.CheckDay() {
+		t.Fatal(next)
+	}
+	if next := c.SetValue("month", 4).ToTime(); next.Format("2006-01-02 15:04:05") != "2030-04-01 09:30:00" {
+		t.Fatal(next)
+	}
+	if v := cron.NextValue(c, "minute", 20, []int{0, 15, 30, 45}); v != 30 {
+		t.Fatal(v)
+	}
+	cj := cron.ParseCronJob("0 30 9 ? * 2 * echo monday")
+	cj.MoveNext()
+	if !cj.CronExpression.ToTime().Equal(cj.NextRunTime()) || cj.CronExpression.String() != "0 30 9 ? * 2 *" {
+		t.Fatal(cj.CronExpression.ToTime(), cj.NextRunTime())
+	}
+	every := cron.ParseCronJob("@every 1h echo hourly")
+	if every.CronExpression != nil {
+		t.Fatal("@every job has a cron expression")
+	}
+	//ToTime and SetTime of the job do not go through the nil CronExpression
+	if ce := every.SetTime(baseTime); ce != nil || !every.ToTime().Equal(baseTime) {
+		t.Fatal(ce, every.ToTime())
+	}
+	if next := every.MoveNextAt(baseTime); !next.After(baseTime) || !every.ToTime().Equal(next) {
+		t.Fatal(next, every.ToTime())
+	}
+	fmt.Println("Test_legacy end")
+}
+
+func Test_overdue(t *testing.T) {
+	fmt.Println("Test_overdue start")
+	cj := cron.ParseCronJob("0 * * * * ? echo minutely")
+	if next := cj.MoveNextAt(baseTime); next.Format("2006-01-02 15:04:05") != "2020-03-06 20:37:00" {
+		t.Fatal(next)
+	}
+	//the daemon was suspended for an hour: the job fires once and skips the 60 missed minutes
+	late := baseTime.Add(time.Hour + 30*time.Second)
+	if next := cj.MoveNextAt(late); next.Format("2006-01-02 15:04:05") != "2020-03-06 21:37:00" {
+		t.Fatal(next)
+	}
+	//ticks on time keep advancing from the fire time
+	if next := cj.MoveNextAt(late.Add(10 * time.Second)); next.Format("2006-01-02 15:04:05") != "2020-03-06 21:38:00" {
+		t.Fatal(next)
+	}
+	fmt.Println("Test_overdue end")
+}